@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"log"
 
 	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 
+	"github.com/example/jsoninputguard/internal/config"
 	"github.com/example/jsoninputguard/internal/predict"
+	"github.com/example/jsoninputguard/internal/reload"
 )
 
 var adapter *chiadapter.ChiLambda
 
 func init() {
-	adapter = chiadapter.New(predict.Router())
+	opts, err := config.FromEnv(reload.Once{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	adapter = chiadapter.New(predict.Router(opts...))
 }
 
 func main() {
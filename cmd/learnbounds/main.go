@@ -0,0 +1,156 @@
+// Command learnbounds derives per-index feature bounds from a JSONL corpus of
+// /predict payloads, for the guard's outlier rule (GUARD_BOUNDS).
+//
+//	learnbounds -in corpus.jsonl -method quantile -lower 0.001 -upper 0.999 > bounds.json
+//	learnbounds -in corpus.jsonl -method stddev -k 4 -action reject -out bounds.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func main() {
+	in := flag.String("in", "-", "JSONL corpus, one payload per line (- for stdin)")
+	out := flag.String("out", "-", "output bounds file (- for stdout)")
+	method := flag.String("method", "quantile", "quantile or stddev")
+	lower := flag.Float64("lower", 0.001, "lower quantile (quantile method)")
+	upper := flag.Float64("upper", 0.999, "upper quantile (quantile method)")
+	k := flag.Float64("k", 4, "standard deviations from the mean (stddev method)")
+	action := flag.String("action", "flag", "flag or reject")
+	flag.Parse()
+
+	// Checked before the corpus is read, which can take a while.
+	switch *method {
+	case "quantile":
+		if !(0 <= *lower && *lower <= *upper && *upper <= 1) {
+			usageError("-lower and -upper must satisfy 0 <= lower <= upper <= 1")
+		}
+	case "stddev":
+		if !(*k > 0) || math.IsInf(*k, 0) {
+			usageError("-k must be a positive number")
+		}
+	default:
+		usageError("unknown -method %q: want quantile or stddev", *method)
+	}
+	if *action != "flag" && *action != "reject" {
+		usageError("unknown -action %q: want flag or reject", *action)
+	}
+
+	r := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	values, accepted, skipped, err := readCorpus(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if accepted == 0 {
+		log.Fatal("no valid payloads in corpus")
+	}
+
+	b := &guard.Bounds{Action: *action, Method: *method}
+	for _, v := range values {
+		var lo, hi float64
+		switch *method {
+		case "quantile":
+			sort.Float64s(v)
+			lo, hi = quantile(v, *lower), quantile(v, *upper)
+		case "stddev":
+			mean, sd := meanStd(v)
+			lo, hi = mean-*k*sd, mean+*k*sd
+		}
+		b.Lower = append(b.Lower, float32(lo))
+		b.Upper = append(b.Upper, float32(hi))
+	}
+
+	doc, _ := json.MarshalIndent(b, "", "  ")
+	if _, err := guard.ParseBounds(doc); err != nil {
+		log.Fatal(err)
+	}
+	doc = append(doc, '\n')
+	if *out == "-" {
+		_, err = os.Stdout.Write(doc)
+	} else {
+		err = os.WriteFile(*out, doc, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "learned %d positions from %d payloads (%d skipped as invalid)\n", len(values), accepted, skipped)
+}
+
+func usageError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "learnbounds: "+format+"\n", args...)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// readCorpus collects the values seen at each position across every payload
+// the guard accepts.
+func readCorpus(r io.Reader) (values [][]float64, accepted, skipped int, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, guard.MaxPayloadSize), 16*guard.MaxPayloadSize)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req types.PredictRequest
+		if guard.GuardAndDecodePredict(line, &req) != nil {
+			skipped++
+			continue
+		}
+		accepted++
+		for i, x := range req.Features {
+			if i == len(values) {
+				values = append(values, nil)
+			}
+			if x == x {
+				values[i] = append(values[i], float64(x))
+			}
+		}
+	}
+	// Bounds stop at the first position with no non-null values.
+	for i, v := range values {
+		if len(v) == 0 {
+			values = values[:i]
+			break
+		}
+	}
+	return values, accepted, skipped, sc.Err()
+}
+
+// quantile interpolates linearly between the closest ranks of sorted v.
+func quantile(v []float64, q float64) float64 {
+	pos := q * float64(len(v)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return v[lo] + (v[hi]-v[lo])*(pos-float64(lo))
+}
+
+func meanStd(v []float64) (float64, float64) {
+	var mean, m2 float64
+	for i, x := range v {
+		d := x - mean
+		mean += d / float64(i+1)
+		m2 += d * (x - mean)
+	}
+	return mean, math.Sqrt(m2 / float64(len(v)))
+}
@@ -0,0 +1,96 @@
+// Command schemadiff classifies the changes between two versions of a
+// request contract as compatible or breaking, and optionally replays a JSONL
+// corpus of past payloads to count those the new version would reject. It
+// exits with status 1 if any change is breaking.
+//
+// A version is a JSON Schema file, or the name of a built-in request type
+// read from its struct tags:
+//
+//	schemadiff -dump PredictRequest > predict.v2.json
+//	schemadiff -old predict.v2.json -new PredictRequest -corpus corpus.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/example/jsoninputguard/internal/schema"
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// builtin are the request types a version can name instead of a file.
+var builtin = map[string]any{
+	"PredictRequest": types.PredictRequest{},
+}
+
+func main() {
+	oldSrc := flag.String("old", "", "old version: JSON Schema file or built-in type")
+	newSrc := flag.String("new", "PredictRequest", "new version: JSON Schema file or built-in type")
+	corpus := flag.String("corpus", "", "JSONL corpus of past payloads to replay against both versions")
+	dump := flag.String("dump", "", "print this version as JSON Schema and exit")
+	flag.Parse()
+
+	if *dump != "" {
+		s := load(*dump)
+		doc, _ := s.MarshalJSON()
+		fmt.Println(string(doc))
+		return
+	}
+	if *oldSrc == "" {
+		log.Fatal("-old is required")
+	}
+	from, to := load(*oldSrc), load(*newSrc)
+
+	breaking := 0
+	changes := schema.Diff(from, to)
+	for _, c := range changes {
+		fmt.Println(c)
+		if c.Breaking {
+			breaking++
+		}
+	}
+	fmt.Printf("%d changes, %d breaking\n", len(changes), breaking)
+
+	if *corpus != "" {
+		f, err := os.Open(*corpus)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		rep, err := schema.Replay(from, to, f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("corpus: %d payloads, %d rejected by old, %d by new, %d newly rejected\n",
+			rep.Payloads, rep.RejectedFrom, rep.RejectedTo, rep.Newly)
+		reasons := make([]string, 0, len(rep.Reasons))
+		for r := range rep.Reasons {
+			reasons = append(reasons, r)
+		}
+		sort.Slice(reasons, func(i, j int) bool { return rep.Reasons[reasons[i]] > rep.Reasons[reasons[j]] })
+		for _, r := range reasons {
+			fmt.Printf("  %6d  %s\n", rep.Reasons[r], r)
+		}
+	}
+	if breaking > 0 {
+		os.Exit(1)
+	}
+}
+
+func load(src string) *schema.Schema {
+	if v, ok := builtin[src]; ok {
+		return schema.MustFromStruct(v)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := schema.Parse(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
@@ -9,7 +9,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/example/jsoninputguard/internal/config"
 	"github.com/example/jsoninputguard/internal/predict"
+	"github.com/example/jsoninputguard/internal/reload"
 )
 
 func main() {
@@ -18,7 +20,23 @@ func main() {
 		addr = ":" + v
 	}
 
-	h := predict.Router()
+	// Configuration files are polled and reloaded on change or SIGHUP. A file
+	// that fails validation leaves the previous version serving.
+	watcher := &reload.Watcher{Interval: 5 * time.Second}
+	if v := os.Getenv("RELOAD_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("RELOAD_INTERVAL: %v", err)
+		}
+		watcher.Interval = d
+	}
+
+	opts, err := config.FromEnv(watcher)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	h := predict.Router(opts...)
 
 	srv := &http.Server{
 		Addr:         addr,
@@ -35,6 +53,19 @@ func main() {
 		}
 	}()
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go watcher.Run(watchCtx)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Printf("SIGHUP: reloading configuration")
+			watcher.Reload()
+		}
+	}()
+
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,281 @@
+// Package abuse temporarily blocks clients that keep sending payloads the
+// guard rejects, so they are answered before their body is read.
+package abuse
+
+import (
+	"container/list"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+)
+
+// Config tunes a Tracker. Zero values take the defaults noted on each field.
+type Config struct {
+	// Window is the sliding window rejections are counted over (1m).
+	Window time.Duration
+	// MinRejections in the window before a client can be blocked (20).
+	MinRejections int
+	// RejectRatio of rejected to total requests that triggers a block (0.5).
+	RejectRatio float64
+	// BlockFor is how long a client stays blocked (5m).
+	BlockFor time.Duration
+	// MaxClients bounds the clients tracked, least recently seen evicted (100000).
+	MaxClients int
+}
+
+// metrics are published with expvar under "abuse" and served on
+// GET /admin/metrics: blocks and unblocks so far, requests refused while
+// blocked, and clients currently blocked.
+var metrics = expvar.NewMap("abuse")
+
+func init() {
+	for _, name := range []string{"blocks", "unblocks", "blocked_requests", "blocked_clients"} {
+		metrics.Set(name, new(expvar.Int))
+	}
+}
+
+// Tracker counts guard rejections per client in a sliding window.
+type Tracker struct {
+	cfg Config
+
+	mu      sync.Mutex
+	clients map[string]*list.Element
+	lru     *list.List // of *client, most recent first
+}
+
+// client keeps two fixed windows; the previous one is weighted by how much
+// of it still overlaps the sliding window.
+type client struct {
+	key          string
+	start        time.Time
+	cur, prev    counts
+	blockedUntil time.Time
+}
+
+type counts struct{ total, rejected int }
+
+// New returns a tracker with cfg's defaults filled in.
+func New(cfg Config) *Tracker {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.MinRejections <= 0 {
+		cfg.MinRejections = 20
+	}
+	if cfg.RejectRatio <= 0 || cfg.RejectRatio > 1 {
+		cfg.RejectRatio = 0.5
+	}
+	if cfg.BlockFor <= 0 {
+		cfg.BlockFor = 5 * time.Minute
+	}
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = 100000
+	}
+	return &Tracker{cfg: cfg, clients: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Blocked reports whether key is blocked at now and for how much longer.
+func (t *Tracker) Blocked(key string, now time.Time) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.clients[key]
+	if !ok {
+		return false, 0
+	}
+	c := e.Value.(*client)
+	if c.blockedUntil.IsZero() {
+		return false, 0
+	}
+	if !now.Before(c.blockedUntil) {
+		c.unblock()
+		return false, 0
+	}
+	return true, c.blockedUntil.Sub(now)
+}
+
+// Observe records one finished request for key and blocks the client once
+// its rejections cross the configured threshold.
+func (t *Tracker) Observe(key string, rejected bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.get(key, now)
+	c.roll(now, t.cfg.Window)
+	c.cur.total++
+	if rejected {
+		c.cur.rejected++
+	}
+	if !rejected || !c.blockedUntil.IsZero() {
+		return
+	}
+	total, rej := c.weighted(now, t.cfg.Window)
+	if rej >= float64(t.cfg.MinRejections) && rej >= t.cfg.RejectRatio*total {
+		c.blockedUntil = now.Add(t.cfg.BlockFor)
+		c.cur, c.prev = counts{}, counts{}
+		metrics.Add("blocks", 1)
+		metrics.Add("blocked_clients", 1)
+	}
+}
+
+func (t *Tracker) get(key string, now time.Time) *client {
+	if e, ok := t.clients[key]; ok {
+		t.lru.MoveToFront(e)
+		return e.Value.(*client)
+	}
+	if t.lru.Len() >= t.cfg.MaxClients {
+		old := t.lru.Back()
+		old.Value.(*client).unblock()
+		t.lru.Remove(old)
+		delete(t.clients, old.Value.(*client).key)
+	}
+	c := &client{key: key, start: now}
+	t.clients[key] = t.lru.PushFront(c)
+	return c
+}
+
+func (c *client) unblock() {
+	if !c.blockedUntil.IsZero() {
+		c.blockedUntil = time.Time{}
+		metrics.Add("blocked_clients", -1)
+	}
+}
+
+func (c *client) roll(now time.Time, window time.Duration) {
+	switch elapsed := now.Sub(c.start); {
+	case elapsed >= 2*window:
+		c.prev, c.cur, c.start = counts{}, counts{}, now
+	case elapsed >= window:
+		c.prev, c.cur, c.start = c.cur, counts{}, c.start.Add(window)
+	}
+}
+
+func (c *client) weighted(now time.Time, window time.Duration) (total, rejected float64) {
+	w := 1 - float64(now.Sub(c.start))/float64(window)
+	if w < 0 {
+		w = 0
+	}
+	return float64(c.cur.total) + w*float64(c.prev.total), float64(c.cur.rejected) + w*float64(c.prev.rejected)
+}
+
+// Unblock lifts key's block and resets its counts. It reports whether the
+// client was blocked.
+func (t *Tracker) Unblock(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.clients[key]
+	if !ok {
+		return false
+	}
+	c := e.Value.(*client)
+	was := !c.blockedUntil.IsZero()
+	c.unblock()
+	c.cur, c.prev = counts{}, counts{}
+	if was {
+		metrics.Add("unblocks", 1)
+	}
+	return was
+}
+
+// Block is one blocked client as listed by the admin endpoint.
+type Block struct {
+	Client string    `json:"client"`
+	Until  time.Time `json:"until"`
+}
+
+// Blocks lists the clients blocked at now, soonest unblocked first.
+func (t *Tracker) Blocks(now time.Time) []Block {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	blocks := []Block{}
+	for key, e := range t.clients {
+		c := e.Value.(*client)
+		if c.blockedUntil.IsZero() {
+			continue
+		}
+		if !now.Before(c.blockedUntil) {
+			c.unblock()
+			continue
+		}
+		blocks = append(blocks, Block{Client: key, Until: c.blockedUntil})
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Until.Before(blocks[j].Until) })
+	return blocks
+}
+
+// rejectedStatus reports whether a response status counts against the
+// client: malformed, oversized, unauthenticated or replayed payloads. Our
+// own shedding (429, 503) does not.
+func rejectedStatus(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusRequestEntityTooLarge:
+		return true
+	}
+	return false
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware answers blocked clients with 403 before the body is read and
+// counts the guard's verdict on everyone else.
+func (t *Tracker) Middleware(key limit.KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := key(r)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if blocked, wait := t.Blocked(id, guard.Now()); blocked {
+				metrics.Add("blocked_requests", 1)
+				guard.WriteError(w, r, &guard.Error{Status: http.StatusForbidden, Code: "client_blocked", Message: "client temporarily blocked after repeated invalid requests", RetryAfter: wait})
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			t.Observe(id, rejectedStatus(sw.status), guard.Now())
+		})
+	}
+}
+
+// Handler serves GET /admin/blocks, listing blocked clients, and
+// DELETE /admin/blocks?client=<key>, unblocking one.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"blocked": t.Blocks(guard.Now())})
+		case http.MethodDelete:
+			if !t.Unblock(r.URL.Query().Get("client")) {
+				guard.WriteError(w, r, &guard.Error{Status: http.StatusNotFound, Code: "not_blocked", Field: "client", Message: "client is not blocked"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
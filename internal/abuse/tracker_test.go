@@ -0,0 +1,69 @@
+package abuse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+)
+
+func TestTracker_BlocksRepeatedRejections(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { guard.Now = orig }(guard.Now)
+	guard.Now = func() time.Time { return now }
+
+	tr := New(Config{Window: time.Minute, MinRejections: 3, RejectRatio: 0.5, BlockFor: time.Minute})
+	calls := 0
+	h := tr.Middleware(limit.ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("bad") != "" {
+			guard.WriteError(w, r, &guard.Error{Status: http.StatusBadRequest, Code: "invalid", Message: "bad"})
+		}
+	}))
+	send := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", target, nil))
+		return rr
+	}
+
+	// Mostly valid traffic is never blocked.
+	for i := 0; i < 10; i++ {
+		send("/")
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusBadRequest, send("/?bad=1").Code)
+	}
+	assert.Empty(t, tr.Blocks(now))
+
+	// Past the window the valid requests age out and rejections dominate.
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		send("/?bad=1")
+	}
+	before := calls
+	rr := send("/")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Equal(t, before, calls, "blocked requests never reach the handler")
+	assert.Equal(t, []Block{{Client: "192.0.2.1", Until: now.Add(time.Minute)}}, tr.Blocks(now))
+
+	// Unblocking through the admin endpoint, then the block expiring.
+	admin := tr.Handler()
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("DELETE", "/admin/blocks?client=192.0.2.1", nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, http.StatusOK, send("/").Code)
+
+	for i := 0; i < 3; i++ {
+		send("/?bad=1")
+	}
+	assert.Equal(t, http.StatusForbidden, send("/").Code)
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, send("/").Code)
+	assert.Empty(t, tr.Blocks(now))
+}
@@ -0,0 +1,163 @@
+// Package auth resolves API keys to tenants and applies each tenant's guard
+// policy and rate limit.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"github.com/example/jsoninputguard/internal/guard"
+)
+
+// Keys is the keys file. Only SHA-256 hashes of the keys are stored:
+//
+//	{"tenants": [{"id": "acme", "key_hashes": ["<hex sha256>"],
+//	  "policy": {"rules": {...}, "bounds": {...}, "rate_limit": {"rps": 50, "burst": 100}}}]}
+type Keys struct {
+	Tenants []*Tenant `json:"tenants"`
+
+	byHash map[[sha256.Size]byte]*Tenant
+}
+
+// Tenant is one API consumer.
+type Tenant struct {
+	ID        string   `json:"id"`
+	KeyHashes []string `json:"key_hashes"`
+	// Admin tenants may call the /admin routes.
+	Admin  bool         `json:"admin,omitempty"`
+	Policy TenantPolicy `json:"policy"`
+
+	bounds *guard.Bounds
+	guard  atomic.Pointer[tenantGuard]
+}
+
+// tenantGuard is a tenant's guard policy with its rules layered over base,
+// the global rules active when it was built.
+type tenantGuard struct {
+	base   *guard.Rules
+	policy *guard.Policy
+}
+
+// TenantPolicy is a tenant's contract. Its rules are layered over the global
+// rules (GUARD_RULES), so fields left out keep the global value, and are
+// layered again when those are reloaded; without bounds the global bounds
+// apply.
+type TenantPolicy struct {
+	Rules     json.RawMessage `json:"rules,omitempty"`
+	Bounds    json.RawMessage `json:"bounds,omitempty"`
+	RateLimit *RateLimit      `json:"rate_limit,omitempty"`
+}
+
+// RateLimit is a token bucket: RPS requests per second, bursting to Burst.
+type RateLimit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// HashKey returns the hex SHA-256 stored in key_hashes for key.
+func HashKey(key string) string {
+	s := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(s[:])
+}
+
+// LoadKeys reads and validates a keys file.
+func LoadKeys(path string) (*Keys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeys(b)
+}
+
+// ParseKeys decodes and validates a keys document, including every tenant's
+// rules and bounds.
+func ParseKeys(b []byte) (*Keys, error) {
+	var k Keys
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+	if len(k.Tenants) == 0 {
+		return nil, errors.New("api keys: no tenants")
+	}
+	k.byHash = make(map[[sha256.Size]byte]*Tenant)
+	ids := map[string]bool{}
+	for _, t := range k.Tenants {
+		if t.ID == "" || len(t.KeyHashes) == 0 {
+			return nil, errors.New("api keys: each tenant needs an id and at least one key hash")
+		}
+		if ids[t.ID] {
+			return nil, fmt.Errorf("api keys: duplicate tenant %q", t.ID)
+		}
+		ids[t.ID] = true
+		for _, h := range t.KeyHashes {
+			var sum [sha256.Size]byte
+			if n, err := hex.Decode(sum[:], []byte(h)); err != nil || n != sha256.Size || len(h) != 2*sha256.Size {
+				return nil, fmt.Errorf("api keys: tenant %q: key hashes must be hex sha256", t.ID)
+			}
+			if _, dup := k.byHash[sum]; dup {
+				return nil, fmt.Errorf("api keys: tenant %q: key hash used twice", t.ID)
+			}
+			k.byHash[sum] = t
+		}
+		if err := t.compile(); err != nil {
+			return nil, fmt.Errorf("api keys: tenant %q: %w", t.ID, err)
+		}
+	}
+	return &k, nil
+}
+
+func (t *Tenant) compile() error {
+	if len(t.Policy.Bounds) > 0 {
+		b, err := guard.ParseBounds(t.Policy.Bounds)
+		if err != nil {
+			return err
+		}
+		t.bounds = b
+	}
+	if rl := t.Policy.RateLimit; rl != nil && (rl.RPS <= 0 || rl.Burst < 0) {
+		return errors.New("rate_limit needs rps > 0 and burst >= 0")
+	}
+	base := guard.ActiveRules()
+	p := &guard.Policy{Bounds: t.bounds}
+	if len(t.Policy.Rules) > 0 {
+		r, err := guard.ParseRulesOver(base, t.Policy.Rules)
+		if err != nil {
+			return err
+		}
+		p.Rules = r
+	}
+	t.guard.Store(&tenantGuard{base: base, policy: p})
+	return nil
+}
+
+// guardPolicy returns the tenant's policy under the global rules now active,
+// layering its rules over them again after a reload. If they no longer
+// validate over the new global rules, the last good layering keeps serving.
+func (t *Tenant) guardPolicy() *guard.Policy {
+	cur := t.guard.Load()
+	base := guard.ActiveRules()
+	if cur.base == base || len(t.Policy.Rules) == 0 {
+		return cur.policy
+	}
+	next := &tenantGuard{base: base, policy: cur.policy}
+	if r, err := guard.ParseRulesOver(base, t.Policy.Rules); err != nil {
+		log.Printf("api keys: tenant %q: rules do not apply over the reloaded global rules, keeping the previous ones: %v", t.ID, err)
+	} else {
+		next.policy = &guard.Policy{Rules: r, Bounds: t.bounds}
+	}
+	// Racing requests layer the same rules; any of them may win.
+	t.guard.Store(next)
+	return next.policy
+}
+
+// lookup finds the tenant owning key. Keys are compared by hash, so the map
+// lookup does not leak timing about stored keys.
+func (k *Keys) lookup(key string) *Tenant {
+	return k.byHash[sha256.Sum256([]byte(key))]
+}
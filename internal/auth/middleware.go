@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+)
+
+// KeyHeader carries the API key; "Authorization: Bearer <key>" also works.
+const KeyHeader = "X-API-Key"
+
+// Authenticator resolves API keys against the active keys file.
+type Authenticator struct {
+	keys atomic.Pointer[Keys]
+
+	mu      sync.Mutex
+	buckets map[string]*limit.Bucket // by tenant id
+}
+
+// New returns an authenticator using keys.
+func New(keys *Keys) *Authenticator {
+	a := &Authenticator{buckets: make(map[string]*limit.Bucket)}
+	a.SetKeys(keys)
+	return a
+}
+
+// SetKeys atomically replaces the keys file. Tenants whose rate limit is
+// unchanged keep their bucket, so a reload does not refill it.
+func (a *Authenticator) SetKeys(keys *Keys) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	buckets := make(map[string]*limit.Bucket)
+	if keys != nil {
+		for _, t := range keys.Tenants {
+			rl := t.Policy.RateLimit
+			if rl == nil {
+				continue
+			}
+			if b, ok := a.buckets[t.ID]; ok && b.Same(rl.RPS, rl.Burst) {
+				buckets[t.ID] = b
+			} else {
+				buckets[t.ID] = limit.NewBucket(rl.RPS, rl.Burst)
+			}
+		}
+	}
+	a.buckets = buckets
+	a.keys.Store(keys)
+}
+
+func (a *Authenticator) bucket(id string) *limit.Bucket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.buckets[id]
+}
+
+type ctxTenantKey struct{}
+
+// TenantFrom returns the tenant authenticated for ctx, if any.
+func TenantFrom(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(ctxTenantKey{}).(*Tenant)
+	return t
+}
+
+var (
+	errMissingKey = &guard.Error{Status: http.StatusUnauthorized, Code: "api_key_missing", Field: KeyHeader, Message: "missing API key"}
+	errInvalidKey = &guard.Error{Status: http.StatusUnauthorized, Code: "api_key_invalid", Field: KeyHeader, Message: "unknown API key"}
+	errForbidden  = &guard.Error{Status: http.StatusForbidden, Code: "forbidden", Message: "admin access required"}
+)
+
+func requestKey(r *http.Request) string {
+	if k := r.Header.Get(KeyHeader); k != "" {
+		return k
+	}
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// Middleware authenticates the request, enforces the tenant's rate limit
+// before the body is read, and installs the tenant's guard policy.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := a.authenticate(r)
+		if err != nil {
+			guard.WriteError(w, r, err)
+			return
+		}
+		if b := a.bucket(t.ID); b != nil {
+			if ok, wait := b.Allow(guard.Now()); !ok {
+				guard.WriteError(w, r, limit.RateLimited("", wait))
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), ctxTenantKey{}, t)
+		next.ServeHTTP(w, r.WithContext(guard.WithPolicy(ctx, t.guardPolicy())))
+	})
+}
+
+// AdminMiddleware only lets admin tenants through.
+func (a *Authenticator) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := a.authenticate(r)
+		if err != nil {
+			guard.WriteError(w, r, err)
+			return
+		}
+		if !t.Admin {
+			guard.WriteError(w, r, errForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxTenantKey{}, t)))
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (*Tenant, error) {
+	key := requestKey(r)
+	if key == "" {
+		return nil, errMissingKey
+	}
+	keys := a.keys.Load()
+	if keys == nil {
+		return nil, errInvalidKey
+	}
+	t := keys.lookup(key)
+	if t == nil {
+		return nil, errInvalidKey
+	}
+	return t, nil
+}
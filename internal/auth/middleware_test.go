@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestMiddleware_TenantPolicy(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { guard.Now = orig }(guard.Now)
+	guard.Now = func() time.Time { return now }
+
+	keys, err := ParseKeys([]byte(fmt.Sprintf(`{"tenants": [
+		{"id": "small", "key_hashes": [%q], "policy": {
+			"rules": {"max_payload_size": 100, "allowed_metadata_keys": ["region"]},
+			"rate_limit": {"rps": 1, "burst": 1}}},
+		{"id": "big", "key_hashes": [%q]}]}`, HashKey("small-key"), HashKey("big-key"))))
+	assert.NoError(t, err)
+	h := New(keys).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		if err := guard.DecodeValidateJSON(w, r, &req, nil); err == nil {
+			w.Write([]byte(TenantFrom(r.Context()).ID))
+		}
+	}))
+	send := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+	ok := `{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`
+	long := `{"user_id":"u","session_id":"s","timestamp":1,"features":[1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25]}`
+
+	assert.Equal(t, http.StatusUnauthorized, send("", ok).Code)
+	assert.Equal(t, http.StatusUnauthorized, send("nope", ok).Code)
+	assert.Equal(t, "big", send("big-key", long).Body.String())
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send("small-key", long).Code)
+	rr := send("small-key", `{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"metadata":{"x":"1"}}`)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	now = now.Add(time.Second)
+	rr = send("small-key", `{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"metadata":{"x":"1"}}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "metadata_key_not_allowed")
+}
+
+func TestMiddleware_TenantRulesLayerOverGlobal(t *testing.T) {
+	defer guard.SetRules(guard.ActiveRules())
+	global, err := guard.ParseRules([]byte(`{"max_features": 2}`))
+	assert.NoError(t, err)
+	guard.SetRules(global)
+
+	keys, err := ParseKeys([]byte(fmt.Sprintf(`{"tenants": [{"id": "a", "key_hashes": [%q],
+		"policy": {"rules": {"allowed_metadata_keys": ["region"]}}}]}`, HashKey("a-key"))))
+	assert.NoError(t, err)
+	h := New(keys).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		_ = guard.DecodeValidateJSON(w, r, &req, nil)
+	}))
+	send := func(body string) int {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+		r.Header.Set(KeyHeader, "a-key")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	three := `{"user_id":"u","session_id":"s","timestamp":1,"features":[1,2,3],"metadata":{"region":"eu"}}`
+	assert.Equal(t, http.StatusBadRequest, send(three), "global max_features applies")
+	assert.Equal(t, http.StatusBadRequest, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"metadata":{"x":"1"}}`))
+
+	// A reload of the global rules is layered under the tenant's again.
+	global, err = guard.ParseRules([]byte(`{"max_features": 4}`))
+	assert.NoError(t, err)
+	guard.SetRules(global)
+	assert.Equal(t, http.StatusOK, send(three))
+	assert.Equal(t, http.StatusBadRequest, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"metadata":{"x":"1"}}`))
+}
+
+func TestParseKeys_Invalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"tenants": []}`,
+		`{"tenants": [{"id": "a", "key_hashes": ["abc"]}]}`,
+		fmt.Sprintf(`{"tenants": [{"id": "a", "key_hashes": [%q]}, {"id": "a", "key_hashes": [%q]}]}`, HashKey("1"), HashKey("2")),
+		fmt.Sprintf(`{"tenants": [{"id": "a", "key_hashes": [%q], "policy": {"rules": {"max_features": 0}}}]}`, HashKey("1")),
+	} {
+		_, err := ParseKeys([]byte(doc))
+		assert.Error(t, err, doc)
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/jsoninputguard/internal/abuse"
+	"github.com/example/jsoninputguard/internal/auth"
+	"github.com/example/jsoninputguard/internal/drift"
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+	"github.com/example/jsoninputguard/internal/predict"
+	"github.com/example/jsoninputguard/internal/preprocess"
+	"github.com/example/jsoninputguard/internal/reload"
+)
+
+// Files registers a configuration file with the loader that validates and
+// applies it. *reload.Watcher keeps reloading it; reload.Once loads it once.
+type Files interface {
+	Add(path string, load reload.Loader) error
+}
+
+// FromEnv builds the router options and global guard settings described by
+// the environment (see README). Every file is loaded through files.
+func FromEnv(w Files) ([]predict.Option, error) {
+	var opts []predict.Option
+	if path := os.Getenv("GUARD_RULES"); path != "" {
+		if err := w.Add(path, func(b []byte) error {
+			r, err := guard.ParseRules(b)
+			if err != nil {
+				return err
+			}
+			guard.SetRules(r)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("guard rules: %w", err)
+		}
+	}
+	if path := os.Getenv("GUARD_BOUNDS"); path != "" {
+		if err := w.Add(path, func(b []byte) error {
+			bd, err := guard.ParseBounds(b)
+			if err != nil {
+				return err
+			}
+			guard.SetBounds(bd)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("guard bounds: %w", err)
+		}
+	}
+	if by := os.Getenv("ABUSE_BLOCK_BY"); by != "" {
+		opt, err := abuseFromEnv(by)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if v := os.Getenv("CONCURRENCY_MAX"); v != "" {
+		cfg := limit.ConcurrencyConfig{}
+		var err error
+		if cfg.Max, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("CONCURRENCY_MAX: %w", err)
+		}
+		if v := os.Getenv("CONCURRENCY_TARGET"); v != "" {
+			if cfg.Target, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("CONCURRENCY_TARGET: %w", err)
+			}
+		}
+		opts = append(opts, predict.WithConcurrencyLimit(limit.NewConcurrency(cfg)))
+	}
+	if by := os.Getenv("RATE_LIMIT_BY"); by != "" {
+		opt, err := rateLimitFromEnv(by)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	// Stages run in order: replay comes after the user_id rate limit, so a
+	// throttled request is rejected before its replay key is recorded.
+	if v := os.Getenv("REPLAY_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("REPLAY_WINDOW: %w", err)
+		}
+		maxEntries := 1 << 20
+		if v := os.Getenv("REPLAY_MAX_ENTRIES"); v != "" {
+			if maxEntries, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("REPLAY_MAX_ENTRIES: %w", err)
+			}
+		}
+		opts = append(opts, predict.WithGuardStages(&guard.ReplayGuard{
+			Store:  guard.NewMemoryReplayStore(maxEntries),
+			Window: window,
+			ByBody: os.Getenv("REPLAY_KEY") == "body",
+		}))
+	}
+	if path := os.Getenv("API_KEYS"); path != "" {
+		a := auth.New(nil)
+		if err := w.Add(path, func(b []byte) error {
+			keys, err := auth.ParseKeys(b)
+			if err != nil {
+				return err
+			}
+			a.SetKeys(keys)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("api keys: %w", err)
+		}
+		opts = append(opts, predict.WithAuth(a))
+	}
+	if path := os.Getenv("SIGNING_KEYS"); path != "" {
+		v := guard.NewSignatureVerifier(nil)
+		if err := w.Add(path, func(b []byte) error {
+			keys, err := guard.ParseSigningKeys(b)
+			if err != nil {
+				return err
+			}
+			v.SetKeys(keys)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("signing keys: %w", err)
+		}
+		opts = append(opts, predict.WithSignatures(v))
+	}
+	if v := os.Getenv("COERCE_ROUTES"); v != "" {
+		opts = append(opts, predict.WithCoercion(strings.Split(v, ",")...))
+	}
+	if v := os.Getenv("INJECTION_RULES"); v != "" {
+		var ids []string
+		if v != "all" {
+			ids = strings.Split(v, ",")
+		}
+		m, err := guard.NewInjectionMatcher(ids...)
+		if err != nil {
+			return nil, fmt.Errorf("INJECTION_RULES: %w", err)
+		}
+		opts = append(opts, predict.WithInjectionRules(m))
+	}
+	if path := os.Getenv("PII_POLICY"); path != "" {
+		scanner := guard.NewPIIScanner(nil)
+		if err := w.Add(path, func(b []byte) error {
+			p, err := guard.ParsePIIPolicy(b)
+			if err != nil {
+				return err
+			}
+			scanner.SetPolicy(p)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("pii policy: %w", err)
+		}
+		opts = append(opts, predict.WithPII(scanner))
+	}
+	if path := os.Getenv("PREPROCESS_MANIFEST"); path != "" {
+		store := preprocess.NewStore(nil)
+		if err := w.Add(path, func(b []byte) error {
+			m, err := preprocess.Parse(b)
+			if err != nil {
+				return err
+			}
+			store.Swap(m)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("preprocess manifest: %w", err)
+		}
+		opts = append(opts, predict.WithPreprocessing(store))
+	}
+	if path := os.Getenv("DRIFT_BASELINE"); path != "" || os.Getenv("DRIFT_MONITOR") != "" {
+		mon := drift.New(drift.Config{})
+		if path != "" {
+			if err := w.Add(path, func(b []byte) error {
+				bl, err := drift.ParseBaseline(b)
+				if err != nil {
+					return err
+				}
+				return mon.SetBaseline(bl)
+			}); err != nil {
+				return nil, fmt.Errorf("drift baseline: %w", err)
+			}
+		}
+		opts = append(opts, predict.WithDriftMonitor(mon))
+	}
+	if files := os.Getenv("MODEL_FILES"); files != "" {
+		reg := predict.NewRegistry()
+		for _, path := range strings.Split(files, ",") {
+			var current string
+			if err := w.Add(path, func(b []byte) error {
+				name, s, err := predict.ParseModel(b)
+				if err != nil {
+					return err
+				}
+				if _, dup := reg.Lookup(name); dup && name != current {
+					return fmt.Errorf("duplicate model %q", name)
+				}
+				if err := reg.Replace(current, name, s); err != nil {
+					return err
+				}
+				current = name
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("models: %w", err)
+			}
+		}
+		if path := os.Getenv("MODEL_SPLITS"); path != "" {
+			if err := w.Add(path, func(b []byte) error {
+				splits, err := predict.ParseSplits(b)
+				if err != nil {
+					return err
+				}
+				return reg.SetSplits(splits)
+			}); err != nil {
+				return nil, fmt.Errorf("model splits: %w", err)
+			}
+		}
+		opts = append(opts, predict.WithModels(reg))
+	}
+
+	return opts, nil
+}
+
+func rateLimitFromEnv(by string) (predict.Option, error) {
+	rps, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_RPS"), 64)
+	if err != nil || rps <= 0 {
+		return nil, fmt.Errorf("RATE_LIMIT_RPS: must be a positive number")
+	}
+	burst := int(math.Ceil(rps))
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		if burst, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_BURST: %w", err)
+		}
+	}
+	maxKeys := 100000
+	if v := os.Getenv("RATE_LIMIT_MAX_KEYS"); v != "" {
+		if maxKeys, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_MAX_KEYS: %w", err)
+		}
+	}
+	l := limit.NewKeyed(rps, burst, maxKeys)
+	switch by {
+	case "ip":
+		return predict.WithRateLimit(l, limit.ByIP), nil
+	case "api_key":
+		return predict.WithRateLimit(l, limit.ByAPIKey), nil
+	case "user_id":
+		return predict.WithGuardStages(l.UserStage()), nil
+	}
+	return nil, fmt.Errorf("RATE_LIMIT_BY: unknown key %q (want ip, api_key or user_id)", by)
+}
+
+func abuseFromEnv(by string) (predict.Option, error) {
+	var key limit.KeyFunc
+	switch by {
+	case "ip":
+		key = limit.ByIP
+	case "api_key":
+		key = byAPIKeyHash
+	default:
+		return nil, fmt.Errorf("ABUSE_BLOCK_BY: unknown key %q (want ip or api_key)", by)
+	}
+	var cfg abuse.Config
+	var err error
+	if v := os.Getenv("ABUSE_WINDOW"); v != "" {
+		if cfg.Window, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("ABUSE_WINDOW: %w", err)
+		}
+	}
+	if v := os.Getenv("ABUSE_BLOCK_FOR"); v != "" {
+		if cfg.BlockFor, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("ABUSE_BLOCK_FOR: %w", err)
+		}
+	}
+	if v := os.Getenv("ABUSE_MIN_REJECTIONS"); v != "" {
+		if cfg.MinRejections, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("ABUSE_MIN_REJECTIONS: %w", err)
+		}
+	}
+	if v := os.Getenv("ABUSE_REJECT_RATIO"); v != "" {
+		if cfg.RejectRatio, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("ABUSE_REJECT_RATIO: %w", err)
+		}
+	}
+	return predict.WithAbuseBlocking(abuse.New(cfg), key), nil
+}
+
+// byAPIKeyHash keys on the hash of the request's API key, as stored in
+// key_hashes, so blocks listed on /admin/blocks do not leak the keys.
+func byAPIKeyHash(r *http.Request) string {
+	if k := limit.ByAPIKey(r); k != "" {
+		return auth.HashKey(k)
+	}
+	return ""
+}
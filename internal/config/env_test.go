@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/auth"
+	"github.com/example/jsoninputguard/internal/predict"
+	"github.com/example/jsoninputguard/internal/reload"
+)
+
+func TestFromEnv_ModelReloadKeepsSplitsServing(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, doc string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+		return path
+	}
+	v1 := write("v1.json", `{"name": "v1", "type": "linear", "weights": [1]}`)
+	v2 := write("v2.json", `{"name": "v2", "type": "linear", "weights": [2]}`)
+	t.Setenv("MODEL_FILES", v1+","+v2)
+	t.Setenv("MODEL_SPLITS", write("splits.json", `{"splits": {"m": {"variants": [
+		{"model": "v1", "weight": 1}, {"model": "v2", "weight": 1}]}}}`))
+
+	w := &reload.Watcher{Logf: t.Logf}
+	opts, err := FromEnv(w)
+	assert.NoError(t, err)
+	router := predict.Router(opts...)
+	post := func(path string) int {
+		body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, post("/predict/m"))
+
+	// Renaming v2 would strand the split's traffic, so the reload is refused.
+	write("v2.json", `{"name": "v3", "type": "linear", "weights": [3]}`)
+	w.Reload()
+	assert.Equal(t, http.StatusOK, post("/predict/v2"))
+	assert.Equal(t, http.StatusNotFound, post("/predict/v3"))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, http.StatusOK, post("/predict/m"))
+	}
+
+	// A new version under the same name goes through.
+	write("v2.json", `{"name": "v2", "type": "linear", "weights": [4]}`)
+	w.Reload()
+	assert.Equal(t, http.StatusOK, post("/predict/m"))
+}
+
+func TestFromEnv_AbuseBlocksByKeyHash(t *testing.T) {
+	r := httptest.NewRequest("POST", "/predict", nil)
+	assert.Equal(t, "", byAPIKeyHash(r))
+	r.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, auth.HashKey("secret"), byAPIKeyHash(r))
+	assert.NotContains(t, byAPIKeyHash(r), "secret")
+
+	t.Setenv("ABUSE_MIN_REJECTIONS", "2")
+	opt, err := abuseFromEnv("api_key")
+	assert.NoError(t, err)
+	router := predict.Router(opt)
+	post := func(key string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/predict", bytes.NewReader([]byte(`{`)))
+		req.Header.Set("X-API-Key", key)
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, post("secret"))
+	assert.Equal(t, http.StatusBadRequest, post("secret"))
+	assert.Equal(t, http.StatusForbidden, post("secret"))
+	assert.Equal(t, http.StatusBadRequest, post("other"))
+}
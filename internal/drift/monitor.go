@@ -0,0 +1,359 @@
+package drift
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Config tunes a Monitor. Zero values take the defaults noted on each field.
+type Config struct {
+	// MaxIndices is how many leading feature positions are tracked (64).
+	MaxIndices int
+	// PSIThreshold flags a feature whose population stability index exceeds it (0.2).
+	PSIThreshold float64
+	// KSThreshold flags a feature whose Kolmogorov-Smirnov distance exceeds it (0.1).
+	KSThreshold float64
+	// MinCount is the number of observations needed before drift is scored (100).
+	MinCount uint64
+}
+
+// Monitor keeps streaming per-index statistics over accepted feature vectors
+// and scores them against an optional baseline. Observations go to one of
+// GOMAXPROCS shards, each behind its own lock, so concurrent requests rarely
+// contend; readers merge the shards.
+type Monitor struct {
+	cfg      Config
+	shards   []*shard
+	baseline atomic.Pointer[Baseline]
+}
+
+type shard struct {
+	mu       sync.Mutex
+	requests uint64
+	stats    []featureStats
+}
+
+type featureStats struct {
+	count    uint64
+	missing  uint64
+	mean, m2 float64 // Welford
+	min, max float64
+	sketch   *sketch
+}
+
+// New returns a monitor with cfg's defaults filled in.
+func New(cfg Config) *Monitor {
+	if cfg.MaxIndices <= 0 {
+		cfg.MaxIndices = 64
+	}
+	if cfg.PSIThreshold <= 0 {
+		cfg.PSIThreshold = 0.2
+	}
+	if cfg.KSThreshold <= 0 {
+		cfg.KSThreshold = 0.1
+	}
+	if cfg.MinCount == 0 {
+		cfg.MinCount = 100
+	}
+	m := &Monitor{cfg: cfg, shards: make([]*shard, runtime.GOMAXPROCS(0))}
+	for i := range m.shards {
+		m.shards[i] = &shard{stats: newStats(cfg.MaxIndices)}
+	}
+	return m
+}
+
+func newStats(n int) []featureStats {
+	stats := make([]featureStats, n)
+	for i := range stats {
+		stats[i] = featureStats{min: math.Inf(1), max: math.Inf(-1), sketch: newSketch()}
+	}
+	return stats
+}
+
+// merge folds o into st, combining the Welford moments as in Chan et al.
+func (st *featureStats) merge(o *featureStats) {
+	st.missing += o.missing
+	if o.count == 0 {
+		return
+	}
+	n := st.count + o.count
+	d := o.mean - st.mean
+	st.mean += d * float64(o.count) / float64(n)
+	st.m2 += o.m2 + d*d*float64(st.count)*float64(o.count)/float64(n)
+	st.count = n
+	st.min = math.Min(st.min, o.min)
+	st.max = math.Max(st.max, o.max)
+	st.sketch.merge(o.sketch)
+}
+
+// merged returns the request count and per-index statistics across shards.
+func (m *Monitor) merged() (uint64, []featureStats) {
+	var requests uint64
+	stats := newStats(m.cfg.MaxIndices)
+	for _, sh := range m.shards {
+		sh.mu.Lock()
+		requests += sh.requests
+		for i := range stats {
+			stats[i].merge(&sh.stats[i])
+		}
+		sh.mu.Unlock()
+	}
+	return requests, stats
+}
+
+// SetBaseline replaces the baseline drift is scored against.
+func (m *Monitor) SetBaseline(b *Baseline) error {
+	for _, f := range b.Features {
+		if f.Index >= m.cfg.MaxIndices {
+			return fmt.Errorf("drift: baseline index %d not tracked (max %d)", f.Index, m.cfg.MaxIndices)
+		}
+	}
+	m.baseline.Store(b)
+	return nil
+}
+
+// Observe records one accepted vector. NaN entries (JSON nulls) count as missing.
+func (m *Monitor) Observe(features []float32) {
+	n := min(len(features), m.cfg.MaxIndices)
+	sh := m.shards[rand.IntN(len(m.shards))]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.requests++
+	for i := 0; i < n; i++ {
+		x := float64(features[i])
+		st := &sh.stats[i]
+		if x != x {
+			st.missing++
+			continue
+		}
+		st.count++
+		d := x - st.mean
+		st.mean += d / float64(st.count)
+		st.m2 += d * (x - st.mean)
+		st.min = math.Min(st.min, x)
+		st.max = math.Max(st.max, x)
+		st.sketch.add(x)
+	}
+}
+
+// Report is the admin view of the monitor.
+type Report struct {
+	Requests uint64          `json:"requests"`
+	Drifted  []int           `json:"drifted"`
+	Features []FeatureReport `json:"features"`
+}
+
+// FeatureReport summarizes one feature position.
+type FeatureReport struct {
+	Index     int                `json:"index"`
+	Count     uint64             `json:"count"`
+	Missing   uint64             `json:"missing"`
+	Mean      float64            `json:"mean"`
+	Variance  float64            `json:"variance"`
+	Min       float64            `json:"min"`
+	Max       float64            `json:"max"`
+	Quantiles map[string]float64 `json:"quantiles"`
+	PSI       *float64           `json:"psi,omitempty"`
+	KS        *float64           `json:"ks,omitempty"`
+	Drift     bool               `json:"drift"`
+}
+
+var (
+	reportQuantiles = []float64{0.01, 0.1, 0.5, 0.9, 0.99}
+	reportLabels    = []string{"p01", "p10", "p50", "p90", "p99"}
+)
+
+// Snapshot computes the current report. Positions never observed are omitted.
+func (m *Monitor) Snapshot() Report {
+	base := map[int]*BaselineFeature{}
+	if b := m.baseline.Load(); b != nil {
+		for i := range b.Features {
+			base[b.Features[i].Index] = &b.Features[i]
+		}
+	}
+
+	requests, stats := m.merged()
+	rep := Report{Requests: requests, Drifted: []int{}}
+	for i := range stats {
+		st := &stats[i]
+		if st.count == 0 && st.missing == 0 {
+			continue
+		}
+		fr := FeatureReport{Index: i, Count: st.count, Missing: st.missing, Quantiles: map[string]float64{}}
+		if st.count > 0 {
+			fr.Mean, fr.Min, fr.Max = st.mean, st.min, st.max
+			fr.Variance = st.m2 / float64(st.count)
+			for qi, v := range st.sketch.quantiles(reportQuantiles) {
+				fr.Quantiles[reportLabels[qi]] = v
+			}
+		}
+		if bf, ok := base[i]; ok && st.count >= m.cfg.MinCount {
+			cur := st.sketch.cdf(bf.Edges)
+			psi, ks := bf.psi(cur), bf.ks(cur)
+			fr.PSI, fr.KS = &psi, &ks
+			fr.Drift = psi > m.cfg.PSIThreshold || ks > m.cfg.KSThreshold
+			if fr.Drift {
+				rep.Drifted = append(rep.Drifted, i)
+			}
+		}
+		rep.Features = append(rep.Features, fr)
+	}
+	return rep
+}
+
+// ExportBaseline turns the current distribution into a decile baseline, so a
+// known-good period can become the reference for later ones.
+func (m *Monitor) ExportBaseline() *Baseline {
+	_, stats := m.merged()
+	b := &Baseline{}
+	qs := []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	for i := range stats {
+		st := &stats[i]
+		if st.count == 0 {
+			continue
+		}
+		edges := dedupe(st.sketch.quantiles(qs))
+		cdf := st.sketch.cdf(edges)
+		props := make([]float64, len(edges)+1)
+		prev := 0.0
+		for k, c := range cdf {
+			props[k] = c - prev
+			prev = c
+		}
+		props[len(edges)] = 1 - prev
+		b.Features = append(b.Features, BaselineFeature{
+			Index: i, Mean: st.mean, Std: math.Sqrt(st.m2 / float64(st.count)),
+			Edges: edges, Proportions: props,
+		})
+	}
+	return b
+}
+
+func dedupe(xs []float64) []float64 {
+	out := xs[:0]
+	for _, x := range xs {
+		if len(out) == 0 || x > out[len(out)-1] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// Handler serves the report as JSON, or the exported baseline with ?baseline=1.
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		if r.URL.Query().Get("baseline") != "" {
+			v = m.ExportBaseline()
+		} else {
+			v = m.Snapshot()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	})
+}
+
+// Baseline is the reference distribution per feature position:
+//
+//	{"features": [{"index": 0, "mean": 0.1, "std": 1.2,
+//	               "edges": [-1, 0, 1], "proportions": [0.1, 0.4, 0.4, 0.1]}]}
+//
+// Edges split the line into len(edges)+1 bins: (-inf, e0], (e0, e1], ..., (ek, +inf).
+type Baseline struct {
+	Features []BaselineFeature `json:"features"`
+}
+
+// BaselineFeature is the reference histogram for one position.
+type BaselineFeature struct {
+	Index       int       `json:"index"`
+	Mean        float64   `json:"mean"`
+	Std         float64   `json:"std"`
+	Edges       []float64 `json:"edges"`
+	Proportions []float64 `json:"proportions"`
+}
+
+// LoadBaseline reads and validates a baseline file.
+func LoadBaseline(path string) (*Baseline, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBaseline(b)
+}
+
+// ParseBaseline decodes and validates a baseline document.
+func ParseBaseline(b []byte) (*Baseline, error) {
+	var bl Baseline
+	if err := json.Unmarshal(b, &bl); err != nil {
+		return nil, fmt.Errorf("drift baseline: %w", err)
+	}
+	for _, f := range bl.Features {
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("drift baseline: index %d: %w", f.Index, err)
+		}
+	}
+	return &bl, nil
+}
+
+func (f *BaselineFeature) validate() error {
+	if f.Index < 0 {
+		return errors.New("negative index")
+	}
+	if len(f.Edges) == 0 || len(f.Proportions) != len(f.Edges)+1 {
+		return errors.New("need edges and len(edges)+1 proportions")
+	}
+	for i := 1; i < len(f.Edges); i++ {
+		if !(f.Edges[i] > f.Edges[i-1]) {
+			return errors.New("edges must be strictly ascending")
+		}
+	}
+	sum := 0.0
+	for _, p := range f.Proportions {
+		if p < 0 {
+			return errors.New("negative proportion")
+		}
+		sum += p
+	}
+	if math.Abs(sum-1) > 0.01 {
+		return errors.New("proportions must sum to 1")
+	}
+	return nil
+}
+
+// psiFloor keeps empty bins from making PSI infinite.
+const psiFloor = 1e-4
+
+// psi compares the current bin proportions, derived from cur (the CDF at
+// each edge), with the baseline's.
+func (f *BaselineFeature) psi(cur []float64) float64 {
+	var psi, prev float64
+	for k := 0; k <= len(cur); k++ {
+		c := 1.0
+		if k < len(cur) {
+			c = cur[k]
+		}
+		a := math.Max(c-prev, psiFloor)
+		e := math.Max(f.Proportions[k], psiFloor)
+		psi += (a - e) * math.Log(a/e)
+		prev = c
+	}
+	return psi
+}
+
+// ks is the largest CDF gap at the baseline's edges.
+func (f *BaselineFeature) ks(cur []float64) float64 {
+	var ks, base float64
+	for k, c := range cur {
+		base += f.Proportions[k]
+		ks = math.Max(ks, math.Abs(c-base))
+	}
+	return ks
+}
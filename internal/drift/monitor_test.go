@@ -0,0 +1,63 @@
+package drift
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor_FlagsShiftedFeature(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ref := New(Config{MaxIndices: 2})
+	for i := 0; i < 5000; i++ {
+		ref.Observe([]float32{float32(rng.NormFloat64()), float32(rng.NormFloat64())})
+	}
+	base := ref.ExportBaseline()
+	assert.Len(t, base.Features, 2)
+
+	m := New(Config{MaxIndices: 2})
+	assert.NoError(t, m.SetBaseline(base))
+	for i := 0; i < 5000; i++ {
+		// Feature 1 drifts by one standard deviation.
+		m.Observe([]float32{float32(rng.NormFloat64()), float32(rng.NormFloat64() + 1)})
+	}
+	rep := m.Snapshot()
+	assert.Equal(t, uint64(5000), rep.Requests)
+	assert.Equal(t, []int{1}, rep.Drifted)
+	assert.InDelta(t, 1.0, rep.Features[1].Mean, 0.1)
+	assert.InDelta(t, 1.0, rep.Features[1].Variance, 0.1)
+	assert.InDelta(t, 1.0, rep.Features[1].Quantiles["p50"], 0.1)
+}
+
+func TestMonitor_MergesShards(t *testing.T) {
+	m := New(Config{MaxIndices: 1})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				m.Observe([]float32{float32(i)})
+			}
+		}()
+	}
+	wg.Wait()
+	rep := m.Snapshot()
+	assert.Equal(t, uint64(800), rep.Requests)
+	f := rep.Features[0]
+	assert.Equal(t, uint64(800), f.Count)
+	assert.InDelta(t, 50.5, f.Mean, 1e-9)
+	assert.InDelta(t, (100*100-1)/12.0, f.Variance, 1e-6) // uniform 1..100
+	assert.Equal(t, 1.0, f.Min)
+	assert.Equal(t, 100.0, f.Max)
+	assert.InDelta(t, 50, f.Quantiles["p50"], 1)
+}
+
+func TestParseBaseline_Invalid(t *testing.T) {
+	_, err := ParseBaseline([]byte(`{"features": [{"index": 0, "edges": [1, 0], "proportions": [0.3, 0.3, 0.4]}]}`))
+	assert.Error(t, err)
+	_, err = ParseBaseline([]byte(`{"features": [{"index": 0, "edges": [0], "proportions": [0.3, 0.3]}]}`))
+	assert.Error(t, err)
+}
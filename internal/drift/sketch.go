@@ -0,0 +1,122 @@
+package drift
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchAlpha is the relative accuracy of quantile estimates.
+const sketchAlpha = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAlpha) / (1 - sketchAlpha)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// sketch is a log-bucketed quantile sketch (DDSketch): a value x lands in
+// bucket ceil(log_gamma(|x|)), so any quantile is returned within
+// sketchAlpha relative error. Values with |x| < minIndexable count as zero.
+type sketch struct {
+	pos, neg map[int]uint64
+	zero     uint64
+	n        uint64
+}
+
+const minIndexable = 1e-9
+
+func newSketch() *sketch {
+	return &sketch{pos: map[int]uint64{}, neg: map[int]uint64{}}
+}
+
+func bucketOf(x float64) int {
+	return int(math.Ceil(math.Log(x) / sketchLogGamma))
+}
+
+// bucketValue is the representative value of bucket k.
+func bucketValue(k int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(k)) / (1 + sketchGamma)
+}
+
+func (s *sketch) add(x float64) {
+	s.n++
+	switch {
+	case x > minIndexable:
+		s.pos[bucketOf(x)]++
+	case x < -minIndexable:
+		s.neg[bucketOf(-x)]++
+	default:
+		s.zero++
+	}
+}
+
+// merge adds o's counts to s.
+func (s *sketch) merge(o *sketch) {
+	for k, n := range o.pos {
+		s.pos[k] += n
+	}
+	for k, n := range o.neg {
+		s.neg[k] += n
+	}
+	s.zero += o.zero
+	s.n += o.n
+}
+
+// entry is one bucket in ascending value order.
+type entry struct {
+	v float64
+	n uint64
+}
+
+func (s *sketch) sorted() []entry {
+	out := make([]entry, 0, len(s.pos)+len(s.neg)+1)
+	for k, n := range s.neg {
+		out = append(out, entry{-bucketValue(k), n})
+	}
+	if s.zero > 0 {
+		out = append(out, entry{0, s.zero})
+	}
+	for k, n := range s.pos {
+		out = append(out, entry{bucketValue(k), n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].v < out[j].v })
+	return out
+}
+
+// quantiles returns the estimates for each q in qs (ascending).
+func (s *sketch) quantiles(qs []float64) []float64 {
+	out := make([]float64, len(qs))
+	if s.n == 0 {
+		return out
+	}
+	entries := s.sorted()
+	var seen uint64
+	j := 0
+	for qi, q := range qs {
+		rank := uint64(q * float64(s.n-1))
+		for j < len(entries)-1 && seen+entries[j].n <= rank {
+			seen += entries[j].n
+			j++
+		}
+		out[qi] = entries[j].v
+	}
+	return out
+}
+
+// cdf returns the fraction of values <= each edge (ascending).
+func (s *sketch) cdf(edges []float64) []float64 {
+	out := make([]float64, len(edges))
+	if s.n == 0 {
+		return out
+	}
+	entries := s.sorted()
+	var seen uint64
+	j := 0
+	for ei, e := range edges {
+		for j < len(entries) && entries[j].v <= e {
+			seen += entries[j].n
+			j++
+		}
+		out[ei] = float64(seen) / float64(s.n)
+	}
+	return out
+}
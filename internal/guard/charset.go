@@ -0,0 +1,270 @@
+package guard
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// CharsetRules set the character policy of the identifier fields.
+//
+//	{"user_id": {"type": "ascii_id"}, "session_id": {"type": "uuid"}}
+type CharsetRules struct {
+	UserID    IDCharset `json:"user_id"`
+	SessionID IDCharset `json:"session_id"`
+}
+
+// IDCharset is one field's character policy. Every type rejects invalid
+// UTF-8, bad escapes, control characters, bidi controls and zero-width
+// characters; lengths are counted in runes after escape decoding, like the
+// validator's max tag.
+type IDCharset struct {
+	// Type is "unicode" (default, any other character), "ascii_id"
+	// (letters, digits and . _ : -), "uuid", "ulid" or "regex".
+	Type string `json:"type,omitempty"`
+	// Pattern is the regular expression a "regex" value must fully match.
+	Pattern string `json:"pattern,omitempty"`
+	// Normalize is "nfc" to rewrite "unicode" values to NFC after decoding.
+	Normalize string `json:"normalize,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (c *IDCharset) validate(field string) error {
+	switch c.Type {
+	case "", "unicode", "ascii_id", "uuid", "ulid":
+		if c.Pattern != "" {
+			return fmt.Errorf("charset %s: pattern needs type regex", field)
+		}
+	case "regex":
+		re, err := regexp.Compile(`\A(?:` + c.Pattern + `)\z`)
+		if err != nil {
+			return fmt.Errorf("charset %s: %w", field, err)
+		}
+		c.re = re
+	default:
+		return fmt.Errorf("charset %s: unknown type %q", field, c.Type)
+	}
+	switch c.Normalize {
+	case "":
+	case "nfc":
+		if c.Type != "" && c.Type != "unicode" {
+			return fmt.Errorf("charset %s: normalize needs type unicode", field)
+		}
+	default:
+		return fmt.Errorf("charset %s: unknown normalization %q", field, c.Normalize)
+	}
+	return nil
+}
+
+func charsetError(field, code, msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: code, Field: field, Message: msg}
+}
+
+// apply checks a decoded value against the policy and normalizes it in place.
+// The characters every type rejects are checked again here, on the value
+// the handler will see, rather than trusting that the scanner saw it.
+func (c *IDCharset) apply(field string, s *string) error {
+	if !utf8.ValidString(*s) {
+		return charsetError(field, "invalid_utf8", "invalid UTF-8")
+	}
+	for _, r := range *s {
+		if forbiddenRune(r) {
+			return charsetError(field, "forbidden_char", "control, bidi or zero-width character")
+		}
+	}
+	ok := true
+	switch c.Type {
+	case "ascii_id":
+		for i := 0; i < len(*s) && ok; i++ {
+			ok = isASCIIIDByte((*s)[i])
+		}
+	case "uuid":
+		ok = isUUID(*s)
+	case "ulid":
+		ok = isULID(*s)
+	case "regex":
+		ok = c.re.MatchString(*s)
+	default:
+		if c.Normalize == "nfc" && !norm.NFC.IsNormalString(*s) {
+			*s = norm.NFC.String(*s)
+		}
+	}
+	if !ok {
+		e := charsetError(field, "charset", "not a valid "+c.Type)
+		e.args = []string{c.Type}
+		return e
+	}
+	return nil
+}
+
+func isASCIIIDByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '.' || b == '_' || b == ':' || b == '-'
+}
+
+func isHex(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < 36; i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isULID accepts 26 Crockford base32 characters whose first one keeps the
+// value within 128 bits.
+func isULID(s string) bool {
+	if len(s) != 26 || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < 26; i++ {
+		b := s[i] | 0x20 // lower case letters
+		switch {
+		case '0' <= s[i] && s[i] <= '9':
+		case 'a' <= b && b <= 'z' && b != 'i' && b != 'l' && b != 'o' && b != 'u':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// forbiddenRune reports control, bidi control and zero-width characters,
+// which render invisibly or reorder text in logs and dashboards.
+func forbiddenRune(r rune) bool {
+	switch {
+	case unicode.IsControl(r):
+		return true
+	case r == 0x061C, r == 0x200E, r == 0x200F, 0x202A <= r && r <= 0x202E, 0x2066 <= r && r <= 0x2069:
+		return true // bidi
+	case 0x200B <= r && r <= 0x200D, r == 0x2060, r == 0xFEFF:
+		return true // zero width
+	}
+	return false
+}
+
+// scanIDString walks the JSON string whose contents start at buf[i],
+// decoding escapes without allocating. It returns the index of the closing
+// quote, or len(buf) if there is none, and the decoded length in runes.
+func scanIDString(buf []byte, i int, field string) (int, int, error) {
+	runes := 0
+	for i < len(buf) {
+		c := buf[i]
+		var r rune
+		switch {
+		case c == '"':
+			return i, runes, nil
+		case c == '\\':
+			var err error
+			if r, i, err = decodeEscape(buf, i, field); err != nil {
+				return i, runes, err
+			}
+		case c < utf8.RuneSelf:
+			r = rune(c)
+			i++
+		default:
+			var size int
+			r, size = utf8.DecodeRune(buf[i:])
+			if r == utf8.RuneError && size == 1 {
+				return i, runes, charsetError(field, "invalid_utf8", "invalid UTF-8")
+			}
+			i += size
+		}
+		if forbiddenRune(r) {
+			return i, runes, charsetError(field, "forbidden_char", "control, bidi or zero-width character")
+		}
+		runes++
+	}
+	return i, runes, nil
+}
+
+// decodeEscape decodes the escape at buf[i] (a backslash), joining UTF-16
+// surrogate pairs, and returns the rune and the index after it.
+func decodeEscape(buf []byte, i int, field string) (rune, int, error) {
+	bad := charsetError(field, "invalid_escape", "invalid escape sequence")
+	if i+1 >= len(buf) {
+		return 0, len(buf), nil // unterminated; the caller reports it
+	}
+	switch buf[i+1] {
+	case '"', '\\', '/':
+		return rune(buf[i+1]), i + 2, nil
+	case 'b':
+		return '\b', i + 2, nil
+	case 'f':
+		return '\f', i + 2, nil
+	case 'n':
+		return '\n', i + 2, nil
+	case 'r':
+		return '\r', i + 2, nil
+	case 't':
+		return '\t', i + 2, nil
+	case 'u':
+		r, ok := hex4(buf, i+2)
+		if !ok {
+			return 0, i, bad
+		}
+		i += 6
+		if utf16.IsSurrogate(r) {
+			if i+1 >= len(buf) || buf[i] != '\\' || buf[i+1] != 'u' {
+				return 0, i, bad
+			}
+			lo, ok := hex4(buf, i+2)
+			if r = utf16.DecodeRune(r, lo); !ok || r == utf8.RuneError {
+				return 0, i, bad
+			}
+			i += 6
+		}
+		return r, i, nil
+	}
+	return 0, i, bad
+}
+
+func hex4(buf []byte, i int) (rune, bool) {
+	if i+4 > len(buf) {
+		return 0, false
+	}
+	var r rune
+	for _, b := range buf[i : i+4] {
+		switch {
+		case '0' <= b && b <= '9':
+			r = r<<4 | rune(b-'0')
+		case 'a' <= b && b <= 'f':
+			r = r<<4 | rune(b-'a'+10)
+		case 'A' <= b && b <= 'F':
+			r = r<<4 | rune(b-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
+
+// applyCharsets runs the per-type checks and normalization on decoded IDs.
+func applyCharsets(rules *Rules, pr *types.PredictRequest) error {
+	if err := rules.Charset.UserID.apply("user_id", &pr.UserID); err != nil {
+		return err
+	}
+	return rules.Charset.SessionID.apply("session_id", &pr.SessionID)
+}
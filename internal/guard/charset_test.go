@@ -0,0 +1,78 @@
+package guard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestCharsets(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"max_user_id_len": 4, "charset": {"user_id": {"normalize": "nfc"}, "session_id": {"type": "uuid"}}}`))
+	assert.NoError(t, err)
+	const sess = `"session_id":"123e4567-e89b-12d3-a456-426614174000"`
+	decode := func(user string) (*types.PredictRequest, error) {
+		var pr types.PredictRequest
+		err := guardAndDecodePredict([]byte(`{"user_id":"`+user+`",`+sess+`,"timestamp":1,"features":[1]}`), &pr, rules)
+		return &pr, err
+	}
+
+	// Escapes are decoded and counted in runes: an escaped é, a surrogate
+	// pair and a combining accent that NFC composes.
+	pr, err := decode(`\u00e9\ud83d\ude00e\u0301`)
+	assert.NoError(t, err)
+	assert.Equal(t, "\u00e9\U0001F600\u00e9", pr.UserID)
+	_, err = decode(`abcde`)
+	assert.EqualError(t, err, "user_id: length out of bounds")
+
+	for user, code := range map[string]string{
+		"a\xffb":   "invalid_utf8",
+		`a\ud83d`:  "invalid_escape",
+		`a\x`:      "invalid_escape",
+		`a\n`:      "forbidden_char",
+		"a\u202eb": "forbidden_char",
+		`a\u200bb`: "forbidden_char",
+		"\ufeffa":  "forbidden_char",
+	} {
+		_, err := decode(user)
+		if assert.IsType(t, &Error{}, err, user) {
+			assert.Equal(t, code, err.(*Error).Code, user)
+		}
+	}
+
+	// The decoded value is checked too, whatever route it took past the scanner.
+	err = applyCharsets(&DefaultRules, &types.PredictRequest{UserID: "u", SessionID: "a\u202eb\u0001"})
+	assert.Equal(t, "forbidden_char", err.(*Error).Code)
+	err = applyCharsets(&DefaultRules, &types.PredictRequest{UserID: "a\xffb", SessionID: "s"})
+	assert.Equal(t, "invalid_utf8", err.(*Error).Code)
+	_, err = decode(`u","USER_ID":"a\u202eb\u0001`)
+	assert.Error(t, err)
+
+	var pr2 types.PredictRequest
+	err = guardAndDecodePredict([]byte(`{"user_id":"u","session_id":"not-a-uuid","timestamp":1,"features":[1]}`), &pr2, rules)
+	assert.Equal(t, &Error{Status: 400, Code: "charset", Field: "session_id", Message: "not a valid uuid", args: []string{"uuid"}}, err)
+
+	for typ, ok := range map[string][]string{
+		"ascii_id": {"user-1.a_b:c"},
+		"ulid":     {"01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+	} {
+		c := IDCharset{Type: typ}
+		assert.NoError(t, c.validate("f"))
+		for _, s := range ok {
+			assert.NoError(t, c.apply("f", &s))
+		}
+	}
+	bad := []struct{ typ, s string }{{"ascii_id", "a b"}, {"ulid", "81ARZ3NDEKTSV4RRFFQ69G5FAV"}, {"ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAU"}}
+	for _, b := range bad {
+		c := IDCharset{Type: b.typ}
+		assert.Error(t, c.apply("f", &b.s), b.s)
+	}
+	re := IDCharset{Type: "regex", Pattern: `u[0-9]+`}
+	assert.NoError(t, re.validate("f"))
+	s := "u12x"
+	assert.Error(t, re.apply("f", &s))
+
+	_, err = ParseRules([]byte(`{"charset": {"user_id": {"type": "uuid", "normalize": "nfc"}}}`))
+	assert.Error(t, err)
+}
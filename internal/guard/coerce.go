@@ -0,0 +1,155 @@
+package guard
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+)
+
+// coercion counts lenient decodes, published with expvar under "coercion":
+// requests that needed it and values converted.
+var coercion = expvar.NewMap("coercion")
+
+type ctxCoerceKey struct{}
+
+// WithCoercion returns middleware that makes DecodeValidateJSON accept
+// numbers sent as strings in a /predict timestamp and features, rewriting
+// them to plain numbers before the scanner runs. Coerced requests are
+// answered with X-Guard-Flags: coerced. Without it decoding stays strict.
+func WithCoercion() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxCoerceKey{}, true)))
+		})
+	}
+}
+
+func coercionFrom(ctx context.Context) bool {
+	on, _ := ctx.Value(ctxCoerceKey{}).(bool)
+	return on
+}
+
+// coerceNumbers unquotes a string timestamp holding an integer and string
+// features holding a JSON number, compacting buf in place. It returns the
+// rewritten buffer and how many values it converted. Anything malformed is
+// left for the scanner to report.
+func coerceNumbers(buf []byte) ([]byte, int) {
+	w, r, n := 0, 0, 0
+	// unquote drops the quotes of the string at buf[s:e].
+	unquote := func(s, e int) {
+		w += copy(buf[w:], buf[r:s])
+		w += copy(buf[w:], buf[s+1:e-1])
+		r = e
+		n++
+	}
+
+	i := skipWS(buf, 0)
+	if i >= len(buf) || buf[i] != '{' {
+		return buf, 0
+	}
+	i++
+scan:
+	for {
+		i = skipWS(buf, i)
+		if i >= len(buf) || buf[i] != '"' {
+			break
+		}
+		keyEnd := skipString(buf, i)
+		if keyEnd > len(buf) {
+			break
+		}
+		key := string(buf[i+1 : keyEnd-1])
+		i = skipWS(buf, keyEnd)
+		if i >= len(buf) || buf[i] != ':' {
+			break
+		}
+		i = skipWS(buf, i+1)
+		end := skipValue(buf, i)
+		if end > len(buf) {
+			break
+		}
+		switch {
+		case key == "timestamp" && buf[i] == '"':
+			if isJSONInt(buf[i+1 : end-1]) {
+				unquote(i, end)
+			}
+		case key == "features" && buf[i] == '[':
+			for j := i + 1; ; {
+				j = skipWS(buf, j)
+				if j >= end || buf[j] == ']' {
+					break
+				}
+				e := skipValue(buf, j)
+				if e > end {
+					break scan
+				}
+				if buf[j] == '"' && isJSONNumber(buf[j+1:e-1]) {
+					unquote(j, e)
+				}
+				if j = skipWS(buf, e); j < end && buf[j] == ',' {
+					j++
+				}
+			}
+		}
+		i = skipWS(buf, end)
+		if i < len(buf) && buf[i] == ',' {
+			i++
+		}
+	}
+	if n == 0 {
+		return buf, 0
+	}
+	w += copy(buf[w:], buf[r:])
+	return buf[:w], n
+}
+
+func isJSONInt(b []byte) bool {
+	if len(b) > 0 && b[0] == '-' {
+		b = b[1:]
+	}
+	if len(b) == 0 || (b[0] == '0' && len(b) > 1) {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isJSONNumber matches the JSON number grammar exactly.
+func isJSONNumber(b []byte) bool {
+	i := 0
+	digits := func() bool {
+		start := i
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+		return i > start
+	}
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	if i < len(b) && b[i] == '0' {
+		i++
+	} else if !digits() {
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if !digits() {
+			return false
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return false
+		}
+	}
+	return i == len(b)
+}
@@ -0,0 +1,48 @@
+package guard
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestCoerceNumbers(t *testing.T) {
+	out, n := coerceNumbers([]byte(`{"user_id":"1", "timestamp" : "1700000000", "features":["0.1", 2, "-3e2", "x", "01"], "metadata":{"timestamp":"5"}}`))
+	assert.Equal(t, 3, n)
+	assert.Equal(t, `{"user_id":"1", "timestamp" : 1700000000, "features":[0.1, 2, -3e2, "x", "01"], "metadata":{"timestamp":"5"}}`, string(out))
+
+	for _, s := range []string{"0", "-0.5", "1e10", "1.5E-3"} {
+		assert.True(t, isJSONNumber([]byte(s)), s)
+	}
+	for _, s := range []string{"", "-", "01", "1.", ".5", "1e", " 1", "NaN", "0x10"} {
+		assert.False(t, isJSONNumber([]byte(s)), s)
+	}
+}
+
+func TestDecodeValidateJSON_Coercion(t *testing.T) {
+	body := `{"user_id":"u","session_id":"s","timestamp":"1700000000","features":["0.5",1]}`
+	send := func(h http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body))))
+		return rr
+	}
+	var req types.PredictRequest
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = types.PredictRequest{}
+		_ = DecodeValidateJSON(w, r, &req, nil)
+	})
+
+	rr := send(handler)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "strict by default")
+
+	rr = send(WithCoercion()(handler))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "coerced", rr.Header().Get(FlagsHeader))
+	assert.Equal(t, int64(1700000000), req.Timestamp)
+	assert.Equal(t, []float32{0.5, 1}, req.Features)
+}
@@ -0,0 +1,79 @@
+package guard
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a structured guard rejection. It is rendered as the JSON body of
+// the error response; Code is stable for clients to branch on.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"error"`
+	// RuleIDs name the rules that matched, for rule-pack rejections.
+	RuleIDs []string `json:"rule_ids,omitempty"`
+	// Fields lists every offending field when there are several.
+	Fields []string `json:"fields,omitempty"`
+	// RetryAfter, when set, is sent as Retry-After in whole seconds.
+	RetryAfter time.Duration `json:"-"`
+
+	args []string // fill the {0}, {1}, ... of translated messages
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return e.Field + ": " + e.Message
+	}
+	return e.Message
+}
+
+// WriteError renders err as a JSON error response with its message in the
+// language r's Accept-Language asks for, English if none is supported; r may
+// be nil. Validator failures are reported as 400 with code "invalid" and the
+// failing fields, other errors that are not an *Error as 400 "invalid".
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	lang := language(r)
+	var ge *Error
+	if errors.As(err, &ge) {
+		ge, lang = localize(ge, lang)
+	} else if ge = validationError(err, lang); ge == nil {
+		ge, lang = &Error{Status: http.StatusBadRequest, Code: "invalid", Message: err.Error()}, "en"
+	}
+	status := ge.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	if ge.RetryAfter > 0 {
+		// Round up so clients never retry early.
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(ge.RetryAfter.Seconds())), 10))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(status)
+	b, _ := json.Marshal(ge)
+	_, _ = w.Write(b)
+}
+
+// FlagsHeader lists non-fatal guard findings, comma separated in a single
+// header, so callers know the score may be low-confidence.
+const FlagsHeader = "X-Guard-Flags"
+
+// addFlag appends flag to the FlagsHeader value, once.
+func addFlag(w http.ResponseWriter, flag string) {
+	h := w.Header()
+	cur := h.Get(FlagsHeader)
+	switch {
+	case cur == "":
+		h.Set(FlagsHeader, flag)
+	case !slices.Contains(strings.Split(cur, ","), flag):
+		h.Set(FlagsHeader, cur+","+flag)
+	}
+}
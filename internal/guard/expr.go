@@ -0,0 +1,362 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// Constraint is a named cross-field rule, compiled when the rules load and
+// evaluated on every decoded /predict payload:
+//
+//	{"id": "dim", "expr": "len(features) == metadata.dim"}
+//	{"id": "v2-width", "expr": "if model == 'v2' then len(features) == 512"}
+//	{"id": "fresh", "expr": "abs(now() - timestamp) <= 5m", "message": "stale timestamp"}
+//
+// Fields are user_id, session_id, timestamp, model, features (features[i]
+// for one entry) and metadata (metadata.key or metadata['key']); a missing
+// metadata key is null. Operators are || && ! == != < <= > >= + - * / and
+// "if A then B". Functions: len(x), abs(x), num(x), has(x), now(). Duration
+// literals such as 5m and now() are in the timestamp rules' unit, seconds
+// by default. Strings compared or added to numbers are parsed as numbers.
+type Constraint struct {
+	ID      string `json:"id"`
+	Expr    string `json:"expr"`
+	Message string `json:"message,omitempty"`
+
+	node exprNode
+}
+
+func (c *Constraint) compile() error {
+	if c.ID == "" {
+		return fmt.Errorf("constraint without id")
+	}
+	n, err := parseExpr(c.Expr)
+	if err != nil {
+		return fmt.Errorf("constraint %s: %w", c.ID, err)
+	}
+	c.node = n
+	return nil
+}
+
+// checkConstraints evaluates every constraint and rejects the payload on the
+// first that is not true, naming it. A non-empty model, the one the route
+// selected, is what constraints see as model instead of the body's field.
+func checkConstraints(rules *Rules, pr *types.PredictRequest, model string) error {
+	if len(rules.Constraints) == 0 {
+		return nil
+	}
+	env := &exprEnv{req: pr, rules: rules, model: model}
+	for i := range rules.Constraints {
+		c := &rules.Constraints[i]
+		v, err := c.node.eval(env)
+		if err == nil && v.k == kBool && v.b {
+			continue
+		}
+		msg := c.Message
+		if msg == "" {
+			msg = "constraint " + c.ID + " failed"
+		}
+		if err != nil {
+			msg += ": " + err.Error()
+		}
+		return &Error{Status: http.StatusBadRequest, Code: "constraint", Message: msg, RuleIDs: []string{c.ID}}
+	}
+	return nil
+}
+
+type exprEnv struct {
+	req   *types.PredictRequest
+	rules *Rules
+	model string
+}
+
+func (e *exprEnv) unit() time.Duration {
+	switch e.rules.Timestamp.Unit {
+	case "ms":
+		return time.Millisecond
+	case "ns":
+		return time.Nanosecond
+	}
+	return time.Second
+}
+
+type kind uint8
+
+const (
+	kNull kind = iota
+	kNum
+	kStr
+	kBool
+	kFeatures
+	kMap
+)
+
+type value struct {
+	k kind
+	n float64
+	s string
+	b bool
+	f []float32
+	m map[string]string
+}
+
+func (v value) String() string {
+	switch v.k {
+	case kNum:
+		return strconv.FormatFloat(v.n, 'g', -1, 64)
+	case kStr:
+		return strconv.Quote(v.s)
+	case kBool:
+		return strconv.FormatBool(v.b)
+	case kFeatures:
+		return "features"
+	case kMap:
+		return "metadata"
+	}
+	return "null"
+}
+
+// number converts v for arithmetic and ordering.
+func (v value) number() (float64, error) {
+	switch v.k {
+	case kNum:
+		return v.n, nil
+	case kStr:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(v.s), 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a number", v)
+}
+
+type exprNode interface {
+	eval(e *exprEnv) (value, error)
+}
+
+type (
+	litNode   struct{ v value }
+	durNode   struct{ d time.Duration }
+	fieldNode struct{ name string }
+	indexNode struct{ x, i exprNode }
+	callNode  struct {
+		fn   string
+		args []exprNode
+	}
+	unaryNode struct {
+		op string
+		x  exprNode
+	}
+	binaryNode struct {
+		op   string
+		x, y exprNode
+	}
+)
+
+func (n litNode) eval(*exprEnv) (value, error) { return n.v, nil }
+
+func (n durNode) eval(e *exprEnv) (value, error) {
+	return value{k: kNum, n: float64(n.d) / float64(e.unit())}, nil
+}
+
+func (n fieldNode) eval(e *exprEnv) (value, error) {
+	switch n.name {
+	case "user_id":
+		return value{k: kStr, s: e.req.UserID}, nil
+	case "session_id":
+		return value{k: kStr, s: e.req.SessionID}, nil
+	case "model":
+		if e.model != "" {
+			return value{k: kStr, s: e.model}, nil
+		}
+		return value{k: kStr, s: e.req.Model}, nil
+	case "timestamp":
+		return value{k: kNum, n: float64(e.req.Timestamp)}, nil
+	case "features":
+		return value{k: kFeatures, f: e.req.Features}, nil
+	case "metadata":
+		return value{k: kMap, m: e.req.Metadata}, nil
+	}
+	return value{}, fmt.Errorf("unknown field %s", n.name)
+}
+
+func (n indexNode) eval(e *exprEnv) (value, error) {
+	x, err := n.x.eval(e)
+	if err != nil {
+		return x, err
+	}
+	i, err := n.i.eval(e)
+	if err != nil {
+		return i, err
+	}
+	switch {
+	case x.k == kMap && i.k == kStr:
+		if s, ok := x.m[i.s]; ok {
+			return value{k: kStr, s: s}, nil
+		}
+		return value{}, nil
+	case x.k == kFeatures && i.k == kNum:
+		// Checked as a float first: int() of NaN, Inf or 1e300 is undefined.
+		if math.IsNaN(i.n) || i.n < 0 || i.n >= float64(len(x.f)) || i.n != math.Trunc(i.n) {
+			return value{}, nil
+		}
+		return value{k: kNum, n: float64(x.f[int(i.n)])}, nil
+	}
+	return value{}, fmt.Errorf("cannot index %s with %s", x, i)
+}
+
+var exprFuncs = map[string]int{"len": 1, "abs": 1, "num": 1, "has": 1, "now": 0}
+
+func (n callNode) eval(e *exprEnv) (value, error) {
+	if n.fn == "now" {
+		return value{k: kNum, n: float64(Now().UnixNano()) / float64(e.unit())}, nil
+	}
+	x, err := n.args[0].eval(e)
+	if err != nil {
+		return x, err
+	}
+	switch n.fn {
+	case "has":
+		return value{k: kBool, b: x.k != kNull}, nil
+	case "len":
+		switch x.k {
+		case kStr:
+			return value{k: kNum, n: float64(utf8.RuneCountInString(x.s))}, nil
+		case kFeatures:
+			return value{k: kNum, n: float64(len(x.f))}, nil
+		case kMap:
+			return value{k: kNum, n: float64(len(x.m))}, nil
+		}
+		return value{}, fmt.Errorf("len of %s", x)
+	}
+	f, err := x.number()
+	if err != nil {
+		return value{}, err
+	}
+	if n.fn == "abs" {
+		f = math.Abs(f)
+	}
+	return value{k: kNum, n: f}, nil
+}
+
+func (n unaryNode) eval(e *exprEnv) (value, error) {
+	x, err := n.x.eval(e)
+	if err != nil {
+		return x, err
+	}
+	if n.op == "!" {
+		if x.k != kBool {
+			return value{}, fmt.Errorf("! of %s", x)
+		}
+		return value{k: kBool, b: !x.b}, nil
+	}
+	f, err := x.number()
+	return value{k: kNum, n: -f}, err
+}
+
+func (n binaryNode) eval(e *exprEnv) (value, error) {
+	x, err := n.x.eval(e)
+	if err != nil {
+		return x, err
+	}
+	// Short circuit; "then" only evaluates its right side when the
+	// condition holds.
+	switch n.op {
+	case "||", "&&", "then":
+		if x.k != kBool {
+			return value{}, fmt.Errorf("%s of %s", n.op, x)
+		}
+		if n.op == "||" && x.b || n.op == "&&" && !x.b || n.op == "then" && !x.b {
+			return value{k: kBool, b: n.op != "&&"}, nil
+		}
+		y, err := n.y.eval(e)
+		if err == nil && y.k != kBool {
+			err = fmt.Errorf("%s of %s", n.op, y)
+		}
+		return y, err
+	}
+	y, err := n.y.eval(e)
+	if err != nil {
+		return y, err
+	}
+	switch n.op {
+	case "==", "!=":
+		eq, err := equal(x, y)
+		return value{k: kBool, b: eq == (n.op == "==")}, err
+	}
+	a, err := x.number()
+	if err != nil {
+		return value{}, err
+	}
+	b, err := y.number()
+	if err != nil {
+		return value{}, err
+	}
+	switch n.op {
+	case "<":
+		return value{k: kBool, b: a < b}, nil
+	case "<=":
+		return value{k: kBool, b: a <= b}, nil
+	case ">":
+		return value{k: kBool, b: a > b}, nil
+	case ">=":
+		return value{k: kBool, b: a >= b}, nil
+	case "+":
+		return value{k: kNum, n: a + b}, nil
+	case "-":
+		return value{k: kNum, n: a - b}, nil
+	case "*":
+		return value{k: kNum, n: a * b}, nil
+	}
+	if b == 0 {
+		return value{}, fmt.Errorf("division by zero")
+	}
+	return value{k: kNum, n: a / b}, nil
+}
+
+func equal(x, y value) (bool, error) {
+	switch {
+	case x.k == kNull || y.k == kNull:
+		return x.k == y.k, nil
+	case x.k == kNum || y.k == kNum:
+		a, err := x.number()
+		if err != nil {
+			return false, nil
+		}
+		b, err := y.number()
+		return err == nil && a == b, nil
+	case x.k != y.k:
+		return false, fmt.Errorf("cannot compare %s and %s", x, y)
+	case x.k == kStr:
+		return x.s == y.s, nil
+	case x.k == kBool:
+		return x.b == y.b, nil
+	}
+	return false, fmt.Errorf("cannot compare %s", x)
+}
+
+type ctxModelKey struct{}
+
+// WithRouteModel returns middleware that tells DecodeValidateJSON which model
+// the route selected, e.g. from a /predict/{model} path parameter, so that
+// constraints test the model that will serve the request rather than the
+// body's model field. An empty name leaves the body's field in force.
+func WithRouteModel(model func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxModelKey{}, model(r))))
+		})
+	}
+}
+
+func routeModelFrom(ctx context.Context) string {
+	m, _ := ctx.Value(ctxModelKey{}).(string)
+	return m
+}
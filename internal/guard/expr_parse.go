@@ -0,0 +1,285 @@
+package guard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type token struct {
+	kind byte // 'n' number, 'd' duration, 's' string, 'i' identifier, 'o' operator, 0 end
+	text string
+	pos  int
+}
+
+func lexExpr(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case '0' <= c && c <= '9' || c == '.' && i+1 < len(src) && '0' <= src[i+1] && src[i+1] <= '9':
+			start := i
+			for i < len(src) && ('0' <= src[i] && src[i] <= '9' || src[i] == '.' || src[i] == 'e' && i+1 < len(src) && ('0' <= src[i+1] && src[i+1] <= '9' || src[i+1] == '-' || src[i+1] == '+')) {
+				if src[i] == 'e' {
+					i++
+				}
+				i++
+			}
+			kind := byte('n')
+			if i < len(src) && isIdentByte(src[i]) {
+				for i < len(src) && (isIdentByte(src[i]) || '0' <= src[i] && src[i] <= '9' || src[i] == '.') {
+					i++
+				}
+				kind = 'd'
+			}
+			toks = append(toks, token{kind, src[start:i], start})
+		case c == '\'' || c == '"':
+			start := i
+			var b strings.Builder
+			for i++; ; i++ {
+				if i >= len(src) {
+					return nil, fmt.Errorf("unterminated string at %d", start)
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				} else if src[i] == c {
+					break
+				}
+				b.WriteByte(src[i])
+			}
+			i++
+			toks = append(toks, token{'s', b.String(), start})
+		case isIdentByte(c):
+			start := i
+			for i < len(src) && (isIdentByte(src[i]) || '0' <= src[i] && src[i] <= '9') {
+				i++
+			}
+			toks = append(toks, token{'i', src[start:i], start})
+		default:
+			op := ""
+			for _, o := range []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, token{'o', op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{pos: len(src)}), nil
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+// exprParser is a recursive descent parser, loosest binding first:
+// if/then, ||, &&, !, comparisons, + -, * /, unary minus, primaries.
+type exprParser struct {
+	toks []token
+	i    int
+}
+
+func parseExpr(src string) (exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != 0 {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *exprParser) peek() token { return p.toks[p.i] }
+
+func (p *exprParser) accept(kind byte, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(kind byte, text string) error {
+	if !p.accept(kind, text) {
+		t := p.peek()
+		if t.kind == 0 {
+			return fmt.Errorf("expected %q at end", text)
+		}
+		return fmt.Errorf("expected %q at %d, got %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) cond() (exprNode, error) {
+	if !p.accept('i', "if") {
+		return p.binary(0)
+	}
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect('i', "then"); err != nil {
+		return nil, err
+	}
+	t, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{"then", c, t}, nil
+}
+
+var exprLevels = [][]string{{"||"}, {"&&"}, nil, {"==", "!=", "<", "<=", ">", ">="}, {"+", "-"}, {"*", "/"}}
+
+func (p *exprParser) binary(level int) (exprNode, error) {
+	if level == len(exprLevels) {
+		return p.unary()
+	}
+	if exprLevels[level] == nil { // negation binds between && and comparisons
+		if p.accept('o', "!") {
+			x, err := p.binary(level)
+			return unaryNode{"!", x}, err
+		}
+		return p.binary(level + 1)
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range exprLevels[level] {
+			if t.kind == 'o' && t.text == op {
+				matched = true
+			}
+		}
+		if !matched {
+			return x, nil
+		}
+		p.i++
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryNode{t.text, x, y}
+		if level == 3 { // comparisons do not chain
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if p.accept('o', "-") {
+		x, err := p.unary()
+		return unaryNode{"-", x}, err
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (exprNode, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept('o', "."):
+			t := p.peek()
+			if t.kind != 'i' {
+				return nil, fmt.Errorf("expected name after '.' at %d", t.pos)
+			}
+			p.i++
+			x = indexNode{x, litNode{value{k: kStr, s: t.text}}}
+		case p.accept('o', "["):
+			i, err := p.cond()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect('o', "]"); err != nil {
+				return nil, err
+			}
+			x = indexNode{x, i}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.peek()
+	p.i++
+	switch t.kind {
+	case 'n':
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d", t.text, t.pos)
+		}
+		return litNode{value{k: kNum, n: f}}, nil
+	case 'd':
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("bad duration %q at %d", t.text, t.pos)
+		}
+		return durNode{d}, nil
+	case 's':
+		return litNode{value{k: kStr, s: t.text}}, nil
+	case 'i':
+		switch t.text {
+		case "true", "false":
+			return litNode{value{k: kBool, b: t.text == "true"}}, nil
+		case "null":
+			return litNode{}, nil
+		case "user_id", "session_id", "timestamp", "model", "features", "metadata":
+			return fieldNode{t.text}, nil
+		}
+		arity, ok := exprFuncs[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown name %q at %d", t.text, t.pos)
+		}
+		if err := p.expect('o', "("); err != nil {
+			return nil, err
+		}
+		var args []exprNode
+		for !p.accept('o', ")") {
+			if len(args) > 0 {
+				if err := p.expect('o', ","); err != nil {
+					return nil, err
+				}
+			}
+			a, err := p.cond()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		if len(args) != arity {
+			return nil, fmt.Errorf("%s takes %d argument(s)", t.text, arity)
+		}
+		return callNode{t.text, args}, nil
+	case 'o':
+		if t.text == "(" {
+			x, err := p.cond()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect('o', ")")
+		}
+	case 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
@@ -0,0 +1,84 @@
+package guard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestConstraints(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	rules, err := ParseRules([]byte(`{"constraints": [
+		{"id": "dim", "expr": "!has(metadata.dim) || len(features) == metadata.dim"},
+		{"id": "v2-width", "expr": "if model == 'v2' then len(features) == 4"},
+		{"id": "fresh", "expr": "abs(now() - timestamp) <= 5m", "message": "stale timestamp"},
+		{"id": "first", "expr": "features[0] >= 0 && metadata['env'] != 'test'"}]}`))
+	assert.NoError(t, err)
+
+	ok := func() *types.PredictRequest {
+		return &types.PredictRequest{UserID: "u", SessionID: "s", Timestamp: now.Unix() - 60,
+			Features: []float32{1, 2, 3}, Metadata: map[string]string{"dim": "3"}}
+	}
+	assert.NoError(t, checkConstraints(rules, ok(), ""))
+
+	for id, mutate := range map[string]func(*types.PredictRequest){
+		"dim":      func(pr *types.PredictRequest) { pr.Metadata["dim"] = "4" },
+		"v2-width": func(pr *types.PredictRequest) { pr.Model = "v2" },
+		"fresh":    func(pr *types.PredictRequest) { pr.Timestamp = now.Unix() + 301 },
+		"first":    func(pr *types.PredictRequest) { pr.Metadata["env"] = "test" },
+	} {
+		pr := ok()
+		mutate(pr)
+		err := checkConstraints(rules, pr, "")
+		if assert.IsType(t, &Error{}, err, id) {
+			assert.Equal(t, []string{id}, err.(*Error).RuleIDs)
+		}
+	}
+
+	// The route's model wins over the body's.
+	pr := ok()
+	pr.Model = "v2"
+	assert.NoError(t, checkConstraints(rules, pr, "v1"))
+	pr.Model = ""
+	assert.Error(t, checkConstraints(rules, pr, "v2"))
+
+	pr = ok()
+	pr.Timestamp = 1
+	assert.Equal(t, "stale timestamp", checkConstraints(rules, pr, "").(*Error).Message)
+	pr = ok()
+	pr.Metadata["dim"] = "three"
+	assert.Equal(t, "constraint dim failed", checkConstraints(rules, pr, "").(*Error).Message)
+
+	for src, want := range map[string]bool{
+		"1 + 2 * 3 == 7":             true,
+		"-(1 - 3) / 2 == 1":          true,
+		"!(1 > 2) && 'a' != 'b'":     true,
+		"1500ms == 1.5":              true,
+		"len(user_id) == 0 || 1 < 2": true,
+		"if false then 1 / 0 > 0":    true,
+		"metadata.missing == null":   true,
+		"features[10] == null":       true,
+		"features[1e300] == null":    true,
+		"features[-1e300] == null":   true,
+		"features[0.5] == null":      true,
+	} {
+		n, err := parseExpr(src)
+		if assert.NoError(t, err, src) {
+			v, err := n.eval(&exprEnv{req: &types.PredictRequest{}, rules: &DefaultRules})
+			assert.NoError(t, err, src)
+			assert.Equal(t, value{k: kBool, b: want}, v, src)
+		}
+	}
+	for _, src := range []string{"", "1 +", "len(features", "foo == 1", "len(1, 2)", "1 < 2 < 3", "'open", "5parsecs == 1"} {
+		_, err := parseExpr(src)
+		assert.Error(t, err, src)
+	}
+	_, err = ParseRules([]byte(`{"constraints": [{"id": "x", "expr": "nope("}]}`))
+	assert.Error(t, err)
+}
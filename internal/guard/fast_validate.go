@@ -3,7 +3,8 @@ package guard
 import (
     "bytes"
     "encoding/json"
-    "errors"
+    "math"
+    "net/http"
     "unicode"
 
     "github.com/example/jsoninputguard/internal/types"
@@ -31,17 +32,17 @@ func fastValidatePredict(buf []byte) error {
 	}
 	// Validate features as array length within bounds without decoding numbers
 	if len(g.Features) == 0 {
-		return errors.New("features: empty")
+		return scanError("length", "features", "empty")
 	}
 	if !isJSONArray(g.Features) {
-		return errors.New("features: not array")
+		return scanError("not_array", "features", "not array")
 	}
 	count, ok := fastCountArrayItems(g.Features)
 	if !ok {
-		return errors.New("features: invalid array syntax")
+		return scanError("invalid_json", "features", "invalid array syntax")
 	}
 	if count < 1 || count > 16384 {
-		return errors.New("features: length out of bounds")
+		return scanError("length", "features", "length out of bounds")
 	}
 	return nil
 }
@@ -104,7 +105,41 @@ func validateAndMaybeDecode[T any](buf []byte, dst *T) error {
 // GuardAndDecodePredict validates PredictRequest using sonic AST for minimal work,
 // then decodes once into the destination struct.
 // GuardPredictRaw performs fast structural and size validation without decoding the heavy array.
+// It enforces the active Rules.
 func GuardPredictRaw(buf []byte) error {
+    return guardPredictRaw(buf, ActiveRules())
+}
+
+func guardPredictRaw(buf []byte, rules *Rules) error {
+    if err := checkStructure(buf, &rules.Structure); err != nil {
+        return err
+    }
+    var scan PredictScan
+    return scanPredict(buf, rules, &scan)
+}
+
+// PredictScan is what the raw scanner extracted from an accepted payload.
+// Byte fields alias the request body, which is pooled: they are only valid
+// until the handler returns and must be copied to be retained. Their string
+// escapes are not decoded; key on User and Session instead.
+type PredictScan struct {
+    UserID       []byte
+    SessionID    []byte
+    Timestamp    int64
+    FeatureCount int
+
+    // User and Session are user_id and session_id as the handler sees them:
+    // escapes decoded and, under an nfc charset policy, normalized. They are
+    // set once the payload is decoded, before any Stage runs.
+    User, Session string
+
+    present  uint8  // bit per contract field given a value, see fieldUserID
+    features [2]int // [start, end) of the top-level features array in the body
+}
+
+// scanPredict is the single-pass scanner behind GuardPredictRaw; it fills scan
+// as it goes.
+func scanPredict(buf []byte, rules *Rules, scan *PredictScan) error {
     // Single-pass, zero-allocation scanner for top-level fields
     const (
         stateKey = iota
@@ -116,7 +151,7 @@ func GuardPredictRaw(buf []byte) error {
     // Skip leading spaces
     for i < len(buf) && (buf[i] == ' ' || buf[i] == '\n' || buf[i] == '\r' || buf[i] == '\t') { i++ }
     if i >= len(buf) || buf[i] != '{' {
-        return errors.New("invalid json: not object")
+        return scanError("invalid_json", "", "invalid json: not object")
     }
     i++
 
@@ -124,6 +159,7 @@ func GuardPredictRaw(buf []byte) error {
     var userLen, sessLen int
     var tsOK bool
     var featCount int
+    var seen uint16 // bit per contractNames entry
 
     for i < len(buf) {
         // Skip whitespace and commas
@@ -138,7 +174,7 @@ func GuardPredictRaw(buf []byte) error {
 
         // Parse key string
         if buf[i] != '"' {
-            return errors.New("invalid json: key not string")
+            return scanError("invalid_json", "", "invalid json: key not string")
         }
         i++
         keyStart := i
@@ -156,55 +192,72 @@ func GuardPredictRaw(buf []byte) error {
             i++
         }
         if i >= len(buf) {
-            return errors.New("invalid json: unterminated key")
+            return scanError("invalid_json", "", "invalid json: unterminated key")
         }
         key := buf[keyStart:i]
         i++ // skip closing quote
 
+        // encoding/json decodes escapes in keys, matches them case-insensitively
+        // and lets the last duplicate win, so any other spelling or a repeat of
+        // a contract key would decode a value these checks never saw.
+        if f, exact := contractKey(key); f >= 0 {
+            if !exact {
+                return scanError("ambiguous_key", contractNames[f], "key must be spelled exactly "+contractNames[f])
+            }
+            if seen&(1<<f) != 0 {
+                return scanError("duplicate_key", contractNames[f], "duplicate key")
+            }
+            seen |= 1 << f
+        }
+
         // Skip to ':'
         for i < len(buf) && (buf[i] == ' ' || buf[i] == '\n' || buf[i] == '\r' || buf[i] == '\t') { i++ }
         if i >= len(buf) || buf[i] != ':' {
-            return errors.New("invalid json: missing colon")
+            return scanError("invalid_json", "", "invalid json: missing colon")
         }
         i++
         for i < len(buf) && (buf[i] == ' ' || buf[i] == '\n' || buf[i] == '\r' || buf[i] == '\t') { i++ }
         if i >= len(buf) {
-            return errors.New("invalid json: missing value")
+            return scanError("invalid_json", "", "invalid json: missing value")
+        }
+
+        // Contract fields: apply the null policy and record presence
+        if f := predictField(key); f >= 0 {
+            if isNull(buf, i) {
+                present, err := rules.nullValue(f)
+                if err != nil { return err }
+                if present { scan.present |= 1 << f }
+                i += len("null")
+                continue
+            }
+            scan.present |= 1 << f
         }
 
         // Match keys we care about and validate value
         switch {
         case bytes.Equal(key, []byte("user_id")):
-            if buf[i] != '"' { return errors.New("user_id: not string") }
+            if buf[i] != '"' { return scanError("not_string", "user_id", "not string") }
             i++
             start := i
-            escaped = false
-            for i < len(buf) {
-                c := buf[i]
-                if c == '"' && !escaped { break }
-                if c == '\\' && !escaped { escaped = true } else { escaped = false }
-                i++
-            }
-            if i >= len(buf) { return errors.New("user_id: unterminated") }
-            userLen = i - start
-            if userLen < 1 || userLen > 64 { return errors.New("user_id: length out of bounds") }
+            var err error
+            if i, userLen, err = scanIDString(buf, i, "user_id"); err != nil { return err }
+            if i >= len(buf) { return scanError("invalid_json", "user_id", "unterminated") }
+            if userLen < 1 || userLen > rules.MaxUserIDLen { return scanError("length", "user_id", "length out of bounds") }
+            if err := scanTags.userID.checkID("user_id", buf[start:i]); err != nil { return err }
+            scan.UserID = buf[start:i]
             i++ // closing quote
             haveUser = true
 
         case bytes.Equal(key, []byte("session_id")):
-            if buf[i] != '"' { return errors.New("session_id: not string") }
+            if buf[i] != '"' { return scanError("not_string", "session_id", "not string") }
             i++
             start := i
-            escaped = false
-            for i < len(buf) {
-                c := buf[i]
-                if c == '"' && !escaped { break }
-                if c == '\\' && !escaped { escaped = true } else { escaped = false }
-                i++
-            }
-            if i >= len(buf) { return errors.New("session_id: unterminated") }
-            sessLen = i - start
-            if sessLen < 1 || sessLen > 64 { return errors.New("session_id: length out of bounds") }
+            var err error
+            if i, sessLen, err = scanIDString(buf, i, "session_id"); err != nil { return err }
+            if i >= len(buf) { return scanError("invalid_json", "session_id", "unterminated") }
+            if sessLen < 1 || sessLen > rules.MaxSessionIDLen { return scanError("length", "session_id", "length out of bounds") }
+            if err := scanTags.sessionID.checkID("session_id", buf[start:i]); err != nil { return err }
+            scan.SessionID = buf[start:i]
             i++ // closing quote
             haveSess = true
 
@@ -212,23 +265,33 @@ func GuardPredictRaw(buf []byte) error {
             // parse optional minus and digits
             sign := int64(1)
             if buf[i] == '-' { sign = -1; i++ }
-            if i >= len(buf) || buf[i] < '0' || buf[i] > '9' { return errors.New("timestamp: invalid") }
+            if i >= len(buf) || buf[i] < '0' || buf[i] > '9' { return timestampError("timestamp_invalid", "invalid") }
             var n int64
             for i < len(buf) && buf[i] >= '0' && buf[i] <= '9' {
-                n = n*10 + int64(buf[i]-'0')
+                d := int64(buf[i]-'0')
+                if n > (math.MaxInt64-d)/10 {
+                    return timestampError("timestamp_range", "timestamp overflows int64")
+                }
+                n = n*10 + d
                 i++
             }
             n *= sign
             tsOK = n > 0
-            if !tsOK { return errors.New("timestamp: must be > 0") }
+            if !tsOK { return timestampError("timestamp_range", "must be > 0") }
+            if scanTags.timestampEpochMS && !validate.IsEpochMS(n) { return tagError("timestamp", "epoch_ms") }
+            if err := rules.Timestamp.check(n); err != nil { return err }
+            scan.Timestamp = n
             haveTS = true
 
         case bytes.Equal(key, []byte("features")):
-            if buf[i] != '[' { return errors.New("features: not array") }
+            if buf[i] != '[' { return scanError("not_array", "features", "not array") }
             c, end, ok := countArrayItemsAndEnd(buf, i)
-            if !ok { return errors.New("features: invalid array") }
-            if c < 1 || c > 16384 { return errors.New("features: length out of bounds") }
+            if !ok { return scanError("invalid_json", "features", "invalid array") }
+            if c < rules.MinFeatures || c > rules.MaxFeatures { return scanError("length", "features", "length out of bounds") }
+            if scanTags.maxFeatures > 0 && !validate.FeatureVectorLen(c, scanTags.maxFeatures) { return tagError("features", "feature_vector") }
             featCount = c
+            scan.FeatureCount = c
+            scan.features = [2]int{i, end}
             haveFeat = true
             i = end
 
@@ -265,7 +328,7 @@ func GuardPredictRaw(buf []byte) error {
                 }
             case '[':
                 _, end, ok := countArrayItemsAndEnd(buf, i)
-                if !ok { return errors.New("invalid array") }
+                if !ok { return scanError("invalid_json", "", "invalid array") }
                 i = end
             default:
                 // number, true, false, null
@@ -275,12 +338,25 @@ func GuardPredictRaw(buf []byte) error {
         // After value, continue loop to next key or end
     }
 
+    if !haveTS && rules.fields.timestampNow {
+        scan.Timestamp = rules.defaultTimestamp()
+        haveTS = true
+    }
     if !haveUser || !haveSess || !haveTS || !haveFeat || featCount == 0 {
-        return errors.New("missing required fields")
+        err := scanError("missing_fields", "", "missing required fields")
+        for f, have := range [...]bool{fieldUserID: haveUser, fieldSessionID: haveSess, fieldTimestamp: haveTS, fieldFeatures: haveFeat && featCount > 0} {
+            if !have { err.Fields = append(err.Fields, predictFieldNames[f]) }
+        }
+        return err
     }
     return nil
 }
 
+// scanError is a 400 from the raw scanner; code picks its translation.
+func scanError(code, field, msg string) *Error {
+    return &Error{Status: http.StatusBadRequest, Code: code, Field: field, Message: msg}
+}
+
 // countArrayItemsAndEnd counts items in JSON array starting at '[' and returns (count, endIndexAfterBracket, ok)
 func countArrayItemsAndEnd(buf []byte, start int) (int, int, bool) {
     i := start
@@ -422,10 +498,60 @@ func findJSONArrayRaw(buf []byte, field string) ([]byte, bool) {
     return nil, false
 }
 
-// GuardAndDecodePredict validates then decodes.
+// markNullFeatures sets dst[k] to NaN for every null element of the raw
+// features array so preprocessing can tell a missing entry from a real zero.
+func markNullFeatures(arr []byte, dst []float32) {
+    k := 0
+    for i := 1; i < len(arr) && k < len(dst); i++ {
+        switch arr[i] {
+        case ' ', '\n', '\r', '\t':
+        case ',':
+            k++
+        case 'n':
+            if bytes.HasPrefix(arr[i:], []byte("null")) {
+                dst[k] = float32(math.NaN())
+                i += 3
+            }
+        }
+    }
+}
+
+// GuardAndDecodePredict validates then decodes. Null feature entries decode as NaN.
 func GuardAndDecodePredict(buf []byte, dst *types.PredictRequest) error {
-    if err := GuardPredictRaw(buf); err != nil {
+    return guardAndDecodePredict(buf, dst, ActiveRules())
+}
+
+func guardAndDecodePredict(buf []byte, dst *types.PredictRequest, rules *Rules) error {
+    if err := checkStructure(buf, &rules.Structure); err != nil {
         return err
     }
-    return json.Unmarshal(buf, dst)
+    var scan PredictScan
+    if err := scanPredict(buf, rules, &scan); err != nil {
+        return err
+    }
+    if err := decodePredict(buf, dst, &scan); err != nil {
+        return err
+    }
+    applyDefaults(rules, dst, &scan)
+    if err := applyCharsets(rules, dst); err != nil {
+        return err
+    }
+    return checkConstraints(rules, dst, "")
+}
+
+// decodePredict decodes a payload that already passed the scanner, which filled scan.
+// dst.Features may be a pooled slice: encoding/json leaves the entries of
+// null elements untouched, so they are cleared first and then marked NaN
+// from the array the scanner located.
+func decodePredict(buf []byte, dst *types.PredictRequest, scan *PredictScan) error {
+    if f := dst.Features[:0]; cap(f) > 0 {
+        clear(f[:min(cap(f), scan.FeatureCount)])
+    }
+    if err := json.Unmarshal(buf, dst); err != nil {
+        return err
+    }
+    if arr := buf[scan.features[0]:scan.features[1]]; bytes.Contains(arr, []byte("null")) {
+        markNullFeatures(arr, dst.Features)
+    }
+    return nil
 }
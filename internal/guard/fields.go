@@ -0,0 +1,190 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// FieldPolicy is the null handling and default of one PredictRequest field:
+//
+//	{"timestamp": {"null": "missing", "default": "now"},
+//	 "metadata": {"default": {}}, "model": {"null": "missing", "default": "v2"}}
+type FieldPolicy struct {
+	// Null is "reject" (400 null_value), "missing" (as if the key were
+	// absent) or "allow" (the zero value; optional fields only). Required
+	// fields default to "reject", metadata and model to "allow".
+	Null string `json:"null,omitempty"`
+	// Default fills the field when it is absent: "now" for timestamp, in
+	// the timestamp rules' unit (seconds unless "ms" or "ns"), an object
+	// for metadata, a string for model.
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// Top-level PredictRequest fields, as bits of PredictScan.present.
+const (
+	fieldUserID = iota
+	fieldSessionID
+	fieldTimestamp
+	fieldFeatures
+	fieldMetadata
+	fieldModel
+	numPredictFields
+)
+
+var predictFieldNames = [numPredictFields]string{"user_id", "session_id", "timestamp", "features", "metadata", "model"}
+
+func predictField(key []byte) int {
+	for f, name := range predictFieldNames {
+		if string(key) == name {
+			return f
+		}
+	}
+	return -1
+}
+
+// contractNames are the top-level JSON names of types.PredictRequest.
+var contractNames = slices.Sorted(maps.Keys(knownFields(reflect.TypeOf(types.PredictRequest{}))))
+
+// contractKey returns the index in contractNames of the member encoding/json
+// would decode raw, the contents of a top-level key, into: escapes decoded,
+// then matched exactly or else case-insensitively. exact reports whether raw
+// is that name as written; -1 means no member.
+func contractKey(raw []byte) (f int, exact bool) {
+	key := raw
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		var s string
+		if json.Unmarshal(append(append([]byte{'"'}, raw...), '"'), &s) != nil {
+			return -1, false
+		}
+		key = []byte(s)
+	}
+	f = -1
+	for i, name := range contractNames {
+		if string(key) == name {
+			return i, len(key) == len(raw)
+		}
+		if f < 0 && bytes.EqualFold(key, []byte(name)) {
+			f = i
+		}
+	}
+	return f, false
+}
+
+// fieldDefaults is Rules.Fields compiled by Validate.
+type fieldDefaults struct {
+	null         [numPredictFields]string // "" is the field's default policy
+	timestampNow bool
+	metadata     map[string]string
+	model        *string
+}
+
+func (r *Rules) compileFields() error {
+	var d fieldDefaults
+	for name, p := range r.Fields {
+		f := predictField([]byte(name))
+		if f < 0 {
+			return fmt.Errorf("fields: unknown field %q", name)
+		}
+		switch p.Null {
+		case "":
+		case "reject", "missing":
+			d.null[f] = p.Null
+		case "allow":
+			if f != fieldMetadata && f != fieldModel {
+				return fmt.Errorf("fields: %s: null can only be allowed on optional fields", name)
+			}
+			d.null[f] = p.Null
+		default:
+			return fmt.Errorf("fields: %s: unknown null policy %q", name, p.Null)
+		}
+		if len(p.Default) == 0 {
+			continue
+		}
+		var err error
+		switch f {
+		case fieldTimestamp:
+			var s string
+			if err = json.Unmarshal(p.Default, &s); err == nil && s != "now" {
+				err = fmt.Errorf(`only "now" is supported`)
+			}
+			d.timestampNow = true
+		case fieldMetadata:
+			err = json.Unmarshal(p.Default, &d.metadata)
+			if err == nil && d.metadata == nil {
+				d.metadata = map[string]string{}
+			}
+		case fieldModel:
+			d.model = new(string)
+			err = json.Unmarshal(p.Default, d.model)
+		default:
+			err = fmt.Errorf("required field has no default")
+		}
+		if err != nil {
+			return fmt.Errorf("fields: %s: default: %w", name, err)
+		}
+	}
+	r.fields = d
+	return nil
+}
+
+// nullValue applies the null policy of field f. It reports whether the
+// field counts as present.
+func (r *Rules) nullValue(f int) (bool, error) {
+	switch r.fields.null[f] {
+	case "missing":
+		return false, nil
+	case "allow":
+		return true, nil
+	case "":
+		if f == fieldMetadata || f == fieldModel {
+			return true, nil
+		}
+	}
+	return false, &Error{Status: http.StatusBadRequest, Code: "null_value", Field: predictFieldNames[f], Message: "must not be null"}
+}
+
+// defaultTimestamp is a server-assigned timestamp in the configured unit.
+func (r *Rules) defaultTimestamp() int64 {
+	now := Now()
+	switch r.Timestamp.Unit {
+	case "ms":
+		return now.UnixMilli()
+	case "ns":
+		return now.UnixNano()
+	}
+	return now.Unix()
+}
+
+// applyDefaults fills the decoded struct with the values the scanner saw or
+// assigned, so both agree.
+func applyDefaults(rules *Rules, pr *types.PredictRequest, scan *PredictScan) {
+	if scan.present&(1<<fieldTimestamp) == 0 {
+		pr.Timestamp = scan.Timestamp
+	}
+	if scan.present&(1<<fieldMetadata) == 0 && rules.fields.metadata != nil {
+		pr.Metadata = make(map[string]string, len(rules.fields.metadata))
+		for k, v := range rules.fields.metadata {
+			pr.Metadata[k] = v
+		}
+	}
+	if scan.present&(1<<fieldModel) == 0 && rules.fields.model != nil {
+		pr.Model = *rules.fields.model
+	}
+}
+
+// isNull reports whether the value at buf[i] is the literal null. Anything
+// else, such as nope or nullx, is left to the field's own checks.
+func isNull(buf []byte, i int) bool {
+	if !bytes.HasPrefix(buf[i:], []byte("null")) {
+		return false
+	}
+	i += len("null")
+	return i == len(buf) || bytes.IndexByte([]byte(",} \n\r\t"), buf[i]) >= 0
+}
@@ -0,0 +1,81 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestFieldPolicies(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_123)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	rules, err := ParseRules([]byte(`{"timestamp": {"unit": "ms"}, "fields": {
+		"timestamp": {"null": "missing", "default": "now"},
+		"metadata": {"null": "missing", "default": {"source": "default"}},
+		"model": {"null": "reject"}}}`))
+	assert.NoError(t, err)
+	decode := func(rules *Rules, body string) (*types.PredictRequest, error) {
+		var pr types.PredictRequest
+		return &pr, guardAndDecodePredict([]byte(body), &pr, rules)
+	}
+
+	// Missing and null-as-missing fields get the same default in the
+	// scanner and the struct.
+	for _, body := range []string{
+		`{"user_id":"u","session_id":"s","features":[1]}`,
+		`{"user_id":"u","session_id":"s","timestamp":null,"features":[1],"metadata":null}`,
+	} {
+		pr, err := decode(rules, body)
+		assert.NoError(t, err, body)
+		assert.Equal(t, now.UnixMilli(), pr.Timestamp)
+		assert.Equal(t, map[string]string{"source": "default"}, pr.Metadata)
+	}
+	var scan PredictScan
+	assert.NoError(t, scanPredict([]byte(`{"user_id":"u","session_id":"s","features":[1]}`), rules, &scan))
+	assert.Equal(t, now.UnixMilli(), scan.Timestamp)
+
+	pr, err := decode(rules, `{"user_id":"u","session_id":"s","timestamp":5,"features":[1],"metadata":{"a":"b"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), pr.Timestamp)
+	assert.Equal(t, map[string]string{"a": "b"}, pr.Metadata)
+
+	_, err = decode(rules, `{"user_id":"u","session_id":"s","features":[1],"model":null}`)
+	assert.Equal(t, &Error{Status: 400, Code: "null_value", Field: "model", Message: "must not be null"}, err)
+
+	// Defaults: nulls are rejected on required fields, allowed on optional ones.
+	_, err = decode(&DefaultRules, `{"user_id":null,"session_id":"s","timestamp":1,"features":[1]}`)
+	assert.Equal(t, &Error{Status: 400, Code: "null_value", Field: "user_id", Message: "must not be null"}, err)
+	pr, err = decode(&DefaultRules, `{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"metadata":null,"model":null}`)
+	assert.NoError(t, err)
+	assert.Nil(t, pr.Metadata)
+
+	// Only the literal null gets the null policy; other words are invalid.
+	for _, body := range []string{
+		`{"user_id":"u","session_id":"s","timestamp":nope,"features":[1]}`,
+		`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"model":nullx}`,
+		`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"model":nul}`,
+	} {
+		_, err := decode(rules, body)
+		assert.Error(t, err, body)
+		var gerr *Error
+		if errors.As(err, &gerr) {
+			assert.NotEqual(t, "null_value", gerr.Code, body)
+		}
+	}
+
+	for _, doc := range []string{
+		`{"fields": {"user_id": {"null": "allow"}}}`,
+		`{"fields": {"features": {"default": [1]}}}`,
+		`{"fields": {"timestamp": {"default": "yesterday"}}}`,
+		`{"fields": {"nope": {}}}`,
+	} {
+		_, err := ParseRules([]byte(doc))
+		assert.Error(t, err, doc)
+	}
+}
@@ -0,0 +1,193 @@
+package guard
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// InjectionRule is one entry of the injection rule pack. Patterns are
+// matched case-insensitively anywhere in a string, with runs of spaces and
+// tabs treated as one space.
+type InjectionRule struct {
+	ID       string
+	Patterns []string
+}
+
+// InjectionRules is the built-in rule pack. IDs are stable; the prefix
+// before the dash is the category.
+var InjectionRules = []InjectionRule{
+	{"sqli-union", []string{"union select", "union all select"}},
+	{"sqli-tautology", []string{"' or '", "' or 1", "\" or \"", "' or true", " or 1=1"}},
+	{"sqli-stacked", []string{"'; drop ", "; drop table", "'; delete ", "'; update ", "; shutdown", "xp_cmdshell"}},
+	{"sqli-comment", []string{"'--", "' --", "'#", "'/*"}},
+	{"sqli-timing", []string{"sleep(", "benchmark(", "waitfor delay", "pg_sleep("}},
+	{"xss-script", []string{"<script", "</script", "javascript:", "vbscript:", "onerror=", "onload=", "<iframe", "<svg"}},
+	{"path-traversal", []string{"../", "..\\", "%2e%2e", "%252e", "/etc/passwd"}},
+	{"crlf-injection", []string{"\r", "\n", "%0d", "%0a"}},
+	{"template-injection", []string{"{{", "${", "<%", "#{", "{%"}},
+}
+
+// InjectionMatcher checks user_id, session_id and metadata values against a
+// set of InjectionRules in one pass per string, using an Aho-Corasick
+// automaton compiled to a DFA over the pattern alphabet.
+type InjectionMatcher struct {
+	rules   []string // ids, indexed by output bit
+	class   [256]uint8
+	classes int
+	next    []int32  // state*classes + class -> state
+	out     []uint64 // state -> bitmask of matched rules
+}
+
+// NewInjectionMatcher compiles the rules selected by ids, each a rule ID or a
+// category such as "sqli". No ids selects the whole pack.
+func NewInjectionMatcher(ids ...string) (*InjectionMatcher, error) {
+	var rules []InjectionRule
+	if len(ids) == 0 {
+		rules = InjectionRules
+	} else {
+		for _, id := range ids {
+			n := len(rules)
+			for _, r := range InjectionRules {
+				if r.ID == id || strings.HasPrefix(r.ID, id+"-") {
+					rules = append(rules, r)
+				}
+			}
+			if len(rules) == n {
+				return nil, fmt.Errorf("injection rules: unknown rule %q", id)
+			}
+		}
+	}
+	if len(rules) > 64 {
+		return nil, fmt.Errorf("injection rules: at most 64 rules")
+	}
+	return compileInjection(rules), nil
+}
+
+func compileInjection(rules []InjectionRule) *InjectionMatcher {
+	m := &InjectionMatcher{classes: 1}
+	for _, r := range rules {
+		for _, p := range r.Patterns {
+			for i := 0; i < len(p); i++ {
+				if c := p[i]; m.class[c] == 0 {
+					m.class[c] = uint8(m.classes)
+					m.classes++
+				}
+			}
+		}
+	}
+
+	// Trie, then failure links breadth first, filling in the DFA as we go.
+	type node struct {
+		child map[uint8]int32
+		out   uint64
+	}
+	trie := []node{{child: map[uint8]int32{}}}
+	for bit, r := range rules {
+		m.rules = append(m.rules, r.ID)
+		for _, p := range r.Patterns {
+			s := int32(0)
+			for i := 0; i < len(p); i++ {
+				c := m.class[p[i]]
+				nx, ok := trie[s].child[c]
+				if !ok {
+					nx = int32(len(trie))
+					trie = append(trie, node{child: map[uint8]int32{}})
+					trie[s].child[c] = nx
+				}
+				s = nx
+			}
+			trie[s].out |= 1 << bit
+		}
+	}
+	m.next = make([]int32, len(trie)*m.classes)
+	m.out = make([]uint64, len(trie))
+	fail := make([]int32, len(trie))
+	queue := []int32{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		m.out[s] = trie[s].out | m.out[fail[s]]
+		for c := 0; c < m.classes; c++ {
+			if nx, ok := trie[s].child[uint8(c)]; ok {
+				if s != 0 {
+					fail[nx] = m.next[int(fail[s])*m.classes+c]
+				}
+				m.next[int(s)*m.classes+c] = nx
+				queue = append(queue, nx)
+			} else if s != 0 {
+				m.next[int(s)*m.classes+c] = m.next[int(fail[s])*m.classes+c]
+			}
+		}
+	}
+	return m
+}
+
+// match returns the bitmask of rules hit anywhere in s.
+func (m *InjectionMatcher) match(s string) uint64 {
+	var hits uint64
+	state := int32(0)
+	space := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\t' {
+			c = ' '
+		}
+		if c == ' ' {
+			if space {
+				continue
+			}
+			space = true
+		} else {
+			space = false
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+		}
+		state = m.next[int(state)*m.classes+int(m.class[c])]
+		hits |= m.out[state]
+	}
+	return hits
+}
+
+func (m *InjectionMatcher) ids(hits uint64) []string {
+	var ids []string
+	for bit, id := range m.rules {
+		if hits&(1<<bit) != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *InjectionMatcher) check(field, s string) error {
+	if hits := m.match(s); hits != 0 {
+		return &Error{Status: http.StatusBadRequest, Code: "injection", Field: field, Message: "value matches injection rules", RuleIDs: m.ids(hits)}
+	}
+	return nil
+}
+
+// Check is a PayloadCheck rejecting the first string field that matches,
+// metadata keys in sorted order.
+func (m *InjectionMatcher) Check(_ http.ResponseWriter, req *types.PredictRequest) error {
+	if err := m.check("user_id", req.UserID); err != nil {
+		return err
+	}
+	if err := m.check("session_id", req.SessionID); err != nil {
+		return err
+	}
+	var bad []string
+	for k, v := range req.Metadata {
+		if m.match(v) != 0 {
+			bad = append(bad, k)
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return m.check("metadata."+bad[0], req.Metadata[bad[0]])
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package guard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestInjectionMatcher(t *testing.T) {
+	m, err := NewInjectionMatcher()
+	assert.NoError(t, err)
+
+	for s, want := range map[string][]string{
+		"user-42":                     nil,
+		"O'Brien":                     nil,
+		"x' OR  '1'='1":               {"sqli-tautology"},
+		"1 UNION\tSELECT password":    {"sqli-union"},
+		"<ScRiPt>alert(1)</script>":   {"xss-script"},
+		"../../etc/passwd":            {"path-traversal"},
+		"a\r\nSet-Cookie: x":          {"crlf-injection"},
+		"{{7*7}}; sleep(5)":           {"sqli-timing", "template-injection"},
+		"abc'; DROP TABLE users; --":  {"sqli-stacked"},
+		"javascript:%2e%2e/${jndi:x}": {"xss-script", "path-traversal", "template-injection"},
+	} {
+		assert.Equal(t, want, m.ids(m.match(s)), s)
+	}
+
+	err = m.Check(nil, &types.PredictRequest{UserID: "u", SessionID: "s",
+		Metadata: map[string]string{"z": "<svg onload=x>", "a": "ok", "b": "../x"}})
+	assert.Equal(t, &Error{Status: 400, Code: "injection", Field: "metadata.b", Message: "value matches injection rules", RuleIDs: []string{"path-traversal"}}, err)
+
+	sqli, err := NewInjectionMatcher("sqli")
+	assert.NoError(t, err)
+	assert.Zero(t, sqli.match("<script>"))
+	assert.NotZero(t, sqli.match("union select"))
+	_, err = NewInjectionMatcher("nosql")
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ import (
     "context"
     "errors"
     "net/http"
+    "reflect"
     "sync"
     "time"
 
@@ -18,11 +19,21 @@ var rawBufferPool = &sync.Pool{New: func() any { b := make([]byte, 0, 64*1024);
 // MaxPayloadSize caps the JSON payload we accept.
 const MaxPayloadSize = 64 * 1024 // 64 KiB
 
+var (
+	errPayloadTooLarge = &Error{Status: http.StatusRequestEntityTooLarge, Code: "payload_too_large", Message: "payload too large"}
+	errEmptyBody       = &Error{Status: http.StatusBadRequest, Code: "empty_body", Message: "empty body"}
+)
+
 // DecodeValidateJSON reads, bounds, decodes with sonic, and optionally validates.
 // It avoids reflection on the hot path by using sonic.Unmarshal.
+// Limits come from the request's Policy, else the rules active when the
+// request starts. On failure the
+// error response has already been written with WriteError.
 func DecodeValidateJSON[T any](w http.ResponseWriter, r *http.Request, dst *T, validateFn func(*T) error) error {
+	rules, bounds := effective(r)
+
 	// Enforce size cap early using http.MaxBytesReader
-	r.Body = http.MaxBytesReader(w, r.Body, MaxPayloadSize)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(rules.MaxPayloadSize))
 	defer r.Body.Close()
 
 	bufPtr := rawBufferPool.Get().(*[]byte)
@@ -33,26 +44,25 @@ func DecodeValidateJSON[T any](w http.ResponseWriter, r *http.Request, dst *T, v
     // Read into preallocated pooled buffer to avoid extra copies
     for {
         if len(buf) == cap(buf) {
-            // Should not happen due to MaxBytesReader, but guard anyway
-            http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
-            return errors.New("payload too large")
+            if len(buf) > rules.MaxPayloadSize {
+                // Should not happen due to MaxBytesReader, but guard anyway
+                WriteError(w, r, errPayloadTooLarge)
+                return errPayloadTooLarge
+            }
+            // Rules allow more than the pooled capacity; grow once.
+            buf = append(buf, make([]byte, rules.MaxPayloadSize+1-len(buf))...)[:len(buf)]
         }
-        // Extend slice for next read chunk
-        nMax := cap(buf) - len(buf)
         // Read directly into the slice tail
-        tmp := (*bufPtr)[:len(buf)+nMax]
-        n, err := r.Body.Read(tmp[len(buf):])
-        if n > 0 {
-            buf = tmp[:len(buf)+n]
-        }
+        n, err := r.Body.Read(buf[len(buf):cap(buf)])
+        buf = buf[:len(buf)+n]
         if err != nil {
-            if err.Error() == "EOF" {
-                break
-            }
-            // For short reads, we may get io.EOF at end; treat as done
-            if n == 0 {
-                break
+            var tooLarge *http.MaxBytesError
+            if errors.As(err, &tooLarge) {
+                WriteError(w, r, errPayloadTooLarge)
+                return errPayloadTooLarge
             }
+            // io.EOF or a short read at the end; treat as done
+            break
         }
         if n == 0 {
             break
@@ -60,30 +70,116 @@ func DecodeValidateJSON[T any](w http.ResponseWriter, r *http.Request, dst *T, v
     }
 
 	if len(buf) == 0 {
-        http.Error(w, "empty body", http.StatusBadRequest)
-		return errors.New("empty body")
+        WriteError(w, r, errEmptyBody)
+		return errEmptyBody
+	}
+
+	for _, check := range rawChecksFrom(r.Context()) {
+		if err := check(r, buf); err != nil {
+			WriteError(w, r, err)
+			return err
+		}
+	}
+
+	// Past schema versions are checked as sent, then migrated in place.
+	if vs := versionsFrom(r.Context()); vs != nil {
+		if _, ok := any(dst).(*types.PredictRequest); ok {
+			migrated, err := vs.migrate(r, buf, rules)
+			if err != nil {
+				WriteError(w, r, err)
+				return err
+			}
+			if migrated != nil {
+				buf = append(buf[:0], migrated...)
+			}
+		}
+	}
+
+	if err := checkStructure(buf, &rules.Structure); err != nil {
+		WriteError(w, r, err)
+		return err
+	}
+
+	// After the raw checks, which see the body exactly as sent.
+	if t := reflect.TypeOf(dst).Elem(); t.Kind() == reflect.Struct {
+		if policy := rules.unknownFieldPolicy(t); policy != UnknownAllow {
+			var err error
+			if buf, err = applyUnknownFields(buf, knownFields(t), policy); err != nil {
+				WriteError(w, r, err)
+				return err
+			}
+		}
 	}
 
 	// Fast path: validate shape from raw, then decode
+	var scan *PredictScan
 	if pr, ok := any(dst).(*types.PredictRequest); ok {
-		if err := GuardAndDecodePredict(buf, pr); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+		if coercionFrom(r.Context()) {
+			var n int
+			if buf, n = coerceNumbers(buf); n > 0 {
+				coercion.Add("requests", 1)
+				coercion.Add("values", int64(n))
+				addFlag(w, "coerced")
+			}
+		}
+		scan = &PredictScan{}
+		if err := scanPredict(buf, rules, scan); err != nil {
+            WriteError(w, r, err)
+			return err
+		}
+		if err := decodePredict(buf, pr, scan); err != nil {
+            WriteError(w, r, err)
+			return err
+		}
+		applyDefaults(rules, pr, scan)
+		if err := applyCharsets(rules, pr); err != nil {
+			WriteError(w, r, err)
+			return err
+		}
+		scan.User, scan.Session = pr.UserID, pr.SessionID
+		if err := checkConstraints(rules, pr, routeModelFrom(r.Context())); err != nil {
+			WriteError(w, r, err)
+			return err
+		}
+		if err := checkMetadataKeys(rules, pr.Metadata); err != nil {
+			WriteError(w, r, err)
 			return err
 		}
+		for _, check := range payloadChecksFrom(r.Context()) {
+			if err := check(w, pr); err != nil {
+				WriteError(w, r, err)
+				return err
+			}
+		}
+		if bounds != nil {
+			if err := checkOutliers(w, bounds, pr.Features); err != nil {
+				WriteError(w, r, err)
+				return err
+			}
+		}
 	} else {
 		if err := json.Unmarshal(buf, dst); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+            WriteError(w, r, err)
 			return err
 		}
 	}
 
 	if validateFn != nil {
 		if err := validateFn(dst); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+            WriteError(w, r, err)
 			return err
 		}
 	}
 
+	if scan != nil {
+		for _, st := range stagesFrom(r.Context()) {
+			if err := st.Check(r, buf, scan); err != nil {
+				WriteError(w, r, err)
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
 )
 
 type testPayload struct {
@@ -30,6 +33,25 @@ func TestDecodeValidateJSON_Valid(t *testing.T) {
 	assert.Equal(t, payload, result)
 }
 
+func TestDecodePredict_NullFeaturesOnPooledSlice(t *testing.T) {
+	// A slice left over from an earlier request, as the handler pools them.
+	pooled := []float32{100, 200, 300}
+	for _, body := range []string{
+		`{"user_id":"u","session_id":"s","timestamp":1,"features":[null,null,null]}`,
+		`{"metadata":{"features":"x"},"user_id":"u","session_id":"s","timestamp":1,"features":[null,null,null]}`,
+		`{"user_id":"u","session_id":"s","timestamp":1,"metadata":{"a":"\"features\":[1]"},"features":[null,null,null]}`,
+	} {
+		copy(pooled[:3], []float32{100, 200, 300})
+		req := types.PredictRequest{Features: pooled[:0]}
+		assert.NoError(t, GuardAndDecodePredict([]byte(body), &req), body)
+		if assert.Len(t, req.Features, 3, body) {
+			for _, f := range req.Features {
+				assert.True(t, math.IsNaN(float64(f)), body)
+			}
+		}
+	}
+}
+
 func TestDecodeValidateJSON_InvalidJSON(t *testing.T) {
 	body := []byte(`{"name": "test", "value": 1,}`)
 	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
@@ -54,3 +76,56 @@ func TestDecodeValidateJSON_ValidationError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
+
+func TestDecodeValidateJSON_OutlierBounds(t *testing.T) {
+	defer SetBounds(nil)
+	body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[0.5,42]}`)
+
+	for _, action := range []string{"flag", "reject"} {
+		b, err := ParseBounds([]byte(`{"action":"` + action + `","lower":[0,0],"upper":[1,10]}`))
+		assert.NoError(t, err)
+		SetBounds(b)
+
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		var result types.PredictRequest
+		err = DecodeValidateJSON(rr, req, &result, nil)
+		if action == "flag" {
+			assert.NoError(t, err)
+			assert.Equal(t, "outlier", rr.Header().Get(FlagsHeader))
+		} else {
+			var ge *Error
+			assert.ErrorAs(t, err, &ge)
+			assert.Equal(t, "features[1]", ge.Field)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestParseRules_OnlyTightens(t *testing.T) {
+	_, err := ParseRules([]byte(`{"max_user_id_len": 32, "max_features": 512}`))
+	assert.NoError(t, err)
+	for _, doc := range []string{
+		`{"max_payload_size": 1048576}`,
+		`{"max_user_id_len": 65}`,
+		`{"max_session_id_len": 128}`,
+		`{"max_features": 20000}`,
+	} {
+		_, err := ParseRules([]byte(doc))
+		assert.Error(t, err, doc)
+	}
+}
+
+func TestParseRulesOver_LeavesBaseUntouched(t *testing.T) {
+	base, err := ParseRules([]byte(`{"max_features": 8, "unknown_fields": {"*": "reject"},
+		"constraints": [{"id": "c", "expr": "len(features) > 0"}]}`))
+	assert.NoError(t, err)
+	r, err := ParseRulesOver(base, []byte(`{"max_user_id_len": 8, "unknown_fields": {"PredictRequest": "strip"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, r.MaxFeatures)
+	assert.Equal(t, 8, r.MaxUserIDLen)
+	assert.Equal(t, map[string]string{"*": "reject", "PredictRequest": "strip"}, r.UnknownFields)
+	assert.Len(t, r.Constraints, 1)
+	assert.Equal(t, map[string]string{"*": "reject"}, base.UnknownFields)
+	assert.Equal(t, 64, base.MaxUserIDLen)
+}
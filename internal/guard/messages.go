@@ -0,0 +1,210 @@
+package guard
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/example/jsoninputguard/internal/validate"
+)
+
+// messages translates Error messages by code. English is the Message as
+// written, and codes missing here, such as constraint messages written by
+// the operator, are sent as written. {fields} is the Error's Fields and
+// {0}, {1}, ... its args.
+var messages = map[string]map[string]string{
+	"fr": {
+		"payload_too_large":        "charge utile trop volumineuse",
+		"empty_body":               "corps de requête vide",
+		"invalid_json":             "JSON invalide",
+		"not_string":               "doit être une chaîne",
+		"not_array":                "doit être un tableau",
+		"length":                   "longueur hors limites",
+		"missing_fields":           "champs obligatoires manquants",
+		"unknown_fields":           "champs inconnus : {fields}",
+		"ambiguous_key":            "la clé doit s'écrire exactement comme dans le contrat",
+		"duplicate_key":            "clé en double",
+		"null_value":               "ne doit pas être null",
+		"max_depth":                "imbrication trop profonde",
+		"max_values":               "trop de valeurs",
+		"max_object_keys":          "trop de clés dans un objet",
+		"max_string_len":           "chaîne trop longue",
+		"max_number_len":           "nombre trop long",
+		"invalid_utf8":             "UTF-8 invalide",
+		"invalid_escape":           "séquence d'échappement invalide",
+		"forbidden_char":           "caractère de contrôle, bidi ou de largeur nulle",
+		"charset":                  "n'est pas un {0} valide",
+		"timestamp_invalid":        "horodatage invalide",
+		"timestamp_range":          "horodatage hors plage",
+		"timestamp_unit":           "unité d'horodatage indéterminée",
+		"timestamp_stale":          "horodatage antérieur à {0}",
+		"timestamp_future":         "horodatage plus de {0} dans le futur",
+		"uuid4_lower":              "doit être un UUID version 4 en minuscules",
+		"epoch_ms":                 "doit être un temps Unix en millisecondes",
+		"feature_vector":           "trop de caractéristiques",
+		"metadata_key_not_allowed": "clé de métadonnées non autorisée",
+		"pii_detected":             "{0} interdit dans les métadonnées",
+		"injection":                "la valeur correspond à des règles d'injection",
+		"outlier":                  "valeur {0} hors des bornes apprises [{1}, {2}]",
+		"replay":                   "requête en double dans la fenêtre de rejeu",
+		"replay_unavailable":       "stockage anti-rejeu indisponible",
+		"signature_missing":        "signature manquante",
+		"signature_malformed":      "en-tête de signature mal formé",
+		"signature_expired":        "horodatage de signature hors tolérance",
+		"signature_invalid":        "la signature ne correspond pas",
+		"api_key_missing":          "clé d'API manquante",
+		"api_key_invalid":          "clé d'API inconnue",
+		"forbidden":                "accès administrateur requis",
+		"rate_limited":             "limite de débit dépassée",
+		"overloaded":               "serveur surchargé",
+		"client_blocked":           "client temporairement bloqué après des requêtes invalides répétées",
+		"not_blocked":              "le client n'est pas bloqué",
+		"unknown_version":          "version de schéma inconnue",
+		"unknown_model":            "modèle inconnu",
+		"feature_count":            "nombre de caractéristiques incompatible avec le modèle",
+		"scoring_failed":           "échec du calcul du score",
+	},
+	"es": {
+		"payload_too_large":        "carga útil demasiado grande",
+		"empty_body":               "cuerpo vacío",
+		"invalid_json":             "JSON no válido",
+		"not_string":               "debe ser una cadena",
+		"not_array":                "debe ser un arreglo",
+		"length":                   "longitud fuera de límites",
+		"missing_fields":           "faltan campos obligatorios",
+		"unknown_fields":           "campos desconocidos: {fields}",
+		"ambiguous_key":            "la clave debe escribirse exactamente como en el contrato",
+		"duplicate_key":            "clave duplicada",
+		"null_value":               "no debe ser null",
+		"max_depth":                "anidamiento demasiado profundo",
+		"max_values":               "demasiados valores",
+		"max_object_keys":          "demasiadas claves en un objeto",
+		"max_string_len":           "cadena demasiado larga",
+		"max_number_len":           "número demasiado largo",
+		"invalid_utf8":             "UTF-8 no válido",
+		"invalid_escape":           "secuencia de escape no válida",
+		"forbidden_char":           "carácter de control, bidi o de ancho cero",
+		"charset":                  "no es un {0} válido",
+		"timestamp_invalid":        "marca de tiempo no válida",
+		"timestamp_range":          "marca de tiempo fuera de rango",
+		"timestamp_unit":           "unidad de marca de tiempo indeterminada",
+		"timestamp_stale":          "marca de tiempo anterior a {0}",
+		"timestamp_future":         "marca de tiempo más de {0} en el futuro",
+		"uuid4_lower":              "debe ser un UUID versión 4 en minúsculas",
+		"epoch_ms":                 "debe ser un tiempo Unix en milisegundos",
+		"feature_vector":           "demasiadas características",
+		"metadata_key_not_allowed": "clave de metadatos no permitida",
+		"pii_detected":             "{0} no permitido en los metadatos",
+		"injection":                "el valor coincide con reglas de inyección",
+		"outlier":                  "valor {0} fuera de los límites aprendidos [{1}, {2}]",
+		"replay":                   "solicitud duplicada dentro de la ventana de repetición",
+		"replay_unavailable":       "almacén anti-repetición no disponible",
+		"signature_missing":        "falta la firma",
+		"signature_malformed":      "cabecera de firma mal formada",
+		"signature_expired":        "marca de tiempo de la firma fuera de tolerancia",
+		"signature_invalid":        "la firma no coincide",
+		"api_key_missing":          "falta la clave de API",
+		"api_key_invalid":          "clave de API desconocida",
+		"forbidden":                "se requiere acceso de administrador",
+		"rate_limited":             "límite de solicitudes superado",
+		"overloaded":               "servidor sobrecargado",
+		"client_blocked":           "cliente bloqueado temporalmente tras solicitudes no válidas repetidas",
+		"not_blocked":              "el cliente no está bloqueado",
+		"unknown_version":          "versión de esquema desconocida",
+		"unknown_model":            "modelo desconocido",
+		"feature_count":            "el número de características no coincide con el modelo",
+		"scoring_failed":           "error al calcular la puntuación",
+	},
+	"de": {
+		"payload_too_large":        "Nutzlast zu groß",
+		"empty_body":               "leerer Anfragetext",
+		"invalid_json":             "ungültiges JSON",
+		"not_string":               "muss eine Zeichenkette sein",
+		"not_array":                "muss ein Array sein",
+		"length":                   "Länge außerhalb der Grenzen",
+		"missing_fields":           "Pflichtfelder fehlen",
+		"unknown_fields":           "unbekannte Felder: {fields}",
+		"ambiguous_key":            "der Schlüssel muss exakt wie im Vertrag geschrieben sein",
+		"duplicate_key":            "doppelter Schlüssel",
+		"null_value":               "darf nicht null sein",
+		"max_depth":                "Verschachtelung zu tief",
+		"max_values":               "zu viele Werte",
+		"max_object_keys":          "zu viele Schlüssel in einem Objekt",
+		"max_string_len":           "Zeichenkette zu lang",
+		"max_number_len":           "Zahl zu lang",
+		"invalid_utf8":             "ungültiges UTF-8",
+		"invalid_escape":           "ungültige Escape-Sequenz",
+		"forbidden_char":           "Steuer-, Bidi- oder Nullbreitenzeichen",
+		"charset":                  "ist keine gültige {0}",
+		"timestamp_invalid":        "ungültiger Zeitstempel",
+		"timestamp_range":          "Zeitstempel außerhalb des Bereichs",
+		"timestamp_unit":           "Zeitstempeleinheit nicht bestimmbar",
+		"timestamp_stale":          "Zeitstempel älter als {0}",
+		"timestamp_future":         "Zeitstempel mehr als {0} in der Zukunft",
+		"uuid4_lower":              "muss eine UUID der Version 4 in Kleinbuchstaben sein",
+		"epoch_ms":                 "muss eine Unix-Zeit in Millisekunden sein",
+		"feature_vector":           "zu viele Merkmale",
+		"metadata_key_not_allowed": "Metadatenschlüssel nicht erlaubt",
+		"pii_detected":             "{0} in Metadaten nicht erlaubt",
+		"injection":                "Wert entspricht Injektionsregeln",
+		"outlier":                  "Wert {0} außerhalb der gelernten Grenzen [{1}, {2}]",
+		"replay":                   "doppelte Anfrage innerhalb des Wiederholungsfensters",
+		"replay_unavailable":       "Wiederholungsspeicher nicht verfügbar",
+		"signature_missing":        "Signatur fehlt",
+		"signature_malformed":      "fehlerhafter Signatur-Header",
+		"signature_expired":        "Signaturzeitstempel außerhalb der Toleranz",
+		"signature_invalid":        "Signatur stimmt nicht überein",
+		"api_key_missing":          "API-Schlüssel fehlt",
+		"api_key_invalid":          "unbekannter API-Schlüssel",
+		"forbidden":                "Administratorzugriff erforderlich",
+		"rate_limited":             "Ratenlimit überschritten",
+		"overloaded":               "Server überlastet",
+		"client_blocked":           "Client nach wiederholten ungültigen Anfragen vorübergehend gesperrt",
+		"not_blocked":              "Client ist nicht gesperrt",
+		"unknown_version":          "unbekannte Schemaversion",
+		"unknown_model":            "unbekanntes Modell",
+		"feature_count":            "Anzahl der Merkmale passt nicht zum Modell",
+		"scoring_failed":           "Bewertung fehlgeschlagen",
+	},
+}
+
+// language picks the language of r's error messages from Accept-Language.
+func language(r *http.Request) string {
+	if r == nil {
+		return validate.Languages[0]
+	}
+	return validate.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// localize returns e with its message in lang, copying rather than
+// changing e since errors are often shared, and the language the message
+// ends up in.
+func localize(e *Error, lang string) (*Error, string) {
+	text, ok := messages[lang][e.Code]
+	if !ok {
+		return e, "en"
+	}
+	if strings.IndexByte(text, '{') >= 0 {
+		pairs := []string{"{fields}", strings.Join(e.Fields, ", ")}
+		for i, arg := range e.args {
+			pairs = append(pairs, "{"+string(rune('0'+i))+"}", arg)
+		}
+		text = strings.NewReplacer(pairs...).Replace(text)
+	}
+	l := *e
+	l.Message = text
+	return &l, lang
+}
+
+// validationError renders the validator's failures in lang. The first
+// failing field is the Error's Field; all of them are in Fields.
+func validationError(err error, lang string) *Error {
+	fields, msgs := validate.Messages(err, lang)
+	if fields == nil {
+		return nil
+	}
+	e := &Error{Status: http.StatusBadRequest, Code: "invalid", Field: fields[0], Message: strings.Join(msgs, "; ")}
+	if len(fields) > 1 {
+		e.Fields = fields
+	}
+	return e
+}
@@ -0,0 +1,61 @@
+package guard
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/validate"
+)
+
+func TestLocalizedErrors(t *testing.T) {
+	send := func(lang, body string, validateFn func(*testPayload) error) (string, map[string]any) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		rr := httptest.NewRecorder()
+		var p testPayload
+		_ = DecodeValidateJSON(rr, req, &p, validateFn)
+		var got map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		return rr.Header().Get("Content-Language"), got
+	}
+
+	lang, got := send("fr-CH, de;q=0.8", "", nil)
+	assert.Equal(t, "fr", lang)
+	assert.Equal(t, "corps de requête vide", got["error"])
+
+	lang, got = send("de", "{}", func(p *testPayload) error { return validate.V().Struct(p) })
+	assert.Equal(t, "de", lang)
+	assert.Equal(t, "name", got["field"])
+	assert.Equal(t, "name ist ein Pflichtfeld; value muss größer als 0 sein", got["error"])
+
+	lang, got = send("es", "{}", func(p *testPayload) error { return validate.V().Struct(p) })
+	assert.Equal(t, "es", lang)
+	assert.Equal(t, []any{"name", "value"}, got["fields"])
+
+	// Unsupported languages fall back to English, the message as written.
+	lang, got = send("ja", "", nil)
+	assert.Equal(t, "en", lang)
+	assert.Equal(t, "empty body", got["error"])
+
+	// Arguments and Fields fill the translation.
+	for lang, want := range map[string]string{
+		"fr": "valeur 9 hors des bornes apprises [0, 1]",
+		"en": "value 9 outside learned bounds [0, 1]",
+	} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		rr := httptest.NewRecorder()
+		err := checkOutliers(rr, &Bounds{Action: "reject", Lower: []float32{0}, Upper: []float32{1}}, []float32{9})
+		WriteError(rr, req, err)
+		assert.Contains(t, rr.Body.String(), want)
+	}
+	rr := httptest.NewRecorder()
+	WriteError(rr, nil, &Error{Code: "unknown_fields", Message: "unknown fields: a, b", Fields: []string{"a", "b"}})
+	assert.Contains(t, rr.Body.String(), `"error":"unknown fields: a, b"`)
+}
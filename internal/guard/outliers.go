@@ -0,0 +1,99 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// Bounds are per-index feature limits learned offline (see cmd/learnbounds).
+// Positions past len(Lower) and null entries are not checked.
+//
+//	{"action": "flag", "method": "quantile", "lower": [0, -3.2], "upper": [1, 3.1]}
+type Bounds struct {
+	// Action is "flag" (default) to accept and set X-Guard-Flags: outlier,
+	// or "reject" to answer 400.
+	Action string    `json:"action,omitempty"`
+	Method string    `json:"method,omitempty"` // how the bounds were learned; informational
+	Lower  []float32 `json:"lower"`
+	Upper  []float32 `json:"upper"`
+}
+
+var activeBounds atomic.Pointer[Bounds]
+
+// ActiveBounds returns the learned bounds in force, or nil when disabled.
+func ActiveBounds() *Bounds { return activeBounds.Load() }
+
+// SetBounds atomically replaces the learned bounds; nil disables the check.
+func SetBounds(b *Bounds) { activeBounds.Store(b) }
+
+// LoadBounds reads and validates a bounds file.
+func LoadBounds(path string) (*Bounds, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBounds(b)
+}
+
+// ParseBounds decodes and validates a bounds document.
+func ParseBounds(b []byte) (*Bounds, error) {
+	var bd Bounds
+	if err := json.Unmarshal(b, &bd); err != nil {
+		return nil, fmt.Errorf("bounds: %w", err)
+	}
+	switch bd.Action {
+	case "":
+		bd.Action = "flag"
+	case "flag", "reject":
+	default:
+		return nil, fmt.Errorf("bounds: unknown action %q", bd.Action)
+	}
+	if len(bd.Lower) != len(bd.Upper) {
+		return nil, fmt.Errorf("bounds: lower and upper lengths differ")
+	}
+	for i := range bd.Lower {
+		if !(bd.Lower[i] <= bd.Upper[i]) {
+			return nil, fmt.Errorf("bounds: index %d: lower > upper", i)
+		}
+	}
+	return &bd, nil
+}
+
+// outlier returns the first position outside the bounds, or -1.
+func (b *Bounds) outlier(features []float32) int {
+	n := len(features)
+	if len(b.Lower) < n {
+		n = len(b.Lower)
+	}
+	for i := 0; i < n; i++ {
+		x := features[i]
+		if x < b.Lower[i] || x > b.Upper[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkOutliers applies b to features: a rejection is returned as an *Error,
+// a flag only sets the response header.
+func checkOutliers(w http.ResponseWriter, b *Bounds, features []float32) error {
+	i := b.outlier(features)
+	if i < 0 {
+		return nil
+	}
+	if b.Action == "reject" {
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    "outlier",
+			Field:   "features[" + strconv.Itoa(i) + "]",
+			Message: fmt.Sprintf("value %g outside learned bounds [%g, %g]", features[i], b.Lower[i], b.Upper[i]),
+			args:    []string{fmt.Sprint(features[i]), fmt.Sprint(b.Lower[i]), fmt.Sprint(b.Upper[i])},
+		}
+	}
+	addFlag(w, "outlier")
+	return nil
+}
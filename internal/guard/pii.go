@@ -0,0 +1,249 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// PIIPolicy chooses what happens when a metadata value contains PII or a
+// secret. Actions are "reject" (400 pii_detected), "redact" (the match is
+// replaced with "[REDACTED:<pattern>]" before the handler sees it) or
+// "flag" (X-Guard-Flags: pii). Patterns left out are not checked. Route
+// entries, keyed by chi route pattern, override the default per pattern:
+//
+//	{"default": {"pan": "reject", "aws_key": "reject", "jwt": "redact", "email": "redact"},
+//	 "routes": {"/predict/{model}": {"email": "flag", "phone": "redact"}}}
+//
+// Patterns: "pan" (Luhn-checked card numbers), "email", "phone", "aws_key"
+// (access key ids) and "jwt".
+type PIIPolicy struct {
+	Default map[string]string            `json:"default"`
+	Routes  map[string]map[string]string `json:"routes,omitempty"`
+
+	def    piiActions
+	routes map[string]piiActions
+}
+
+// piiActions holds one action per entry of piiPatterns; "" is off.
+type piiActions [len(piiPatterns)]string
+
+type piiPattern struct {
+	name string
+	hint func(string) bool // cheap prefilter before the regexp runs
+	re   *regexp.Regexp
+	ok   func(match string) bool // optional post-check on a match
+}
+
+// Order matters when redacting: card numbers are replaced before the phone
+// pattern could claim part of them.
+var piiPatterns = [...]piiPattern{
+	{name: "jwt", hint: func(s string) bool { return strings.Contains(s, "eyJ") },
+		re: regexp.MustCompile(`eyJ[A-Za-z0-9_-]{2,}\.eyJ[A-Za-z0-9_-]{2,}\.[A-Za-z0-9_-]*`)},
+	{name: "aws_key", hint: func(s string) bool { return strings.Contains(s, "AKIA") || strings.Contains(s, "ASIA") },
+		re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "pan", hint: hasDigitRun,
+		re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), ok: luhn},
+	{name: "email", hint: func(s string) bool { return strings.IndexByte(s, '@') >= 0 },
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "phone", hint: hasDigitRun,
+		re: regexp.MustCompile(`\+\d{8,14}\b|(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b`)},
+}
+
+// hasDigitRun reports whether s has at least three digits in a row.
+func hasDigitRun(s string) bool {
+	run := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			if run++; run == 3 {
+				return true
+			}
+		} else {
+			run = 0
+		}
+	}
+	return false
+}
+
+// luhn validates the check digit of a card number, ignoring separators.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return sum%10 == 0
+}
+
+// LoadPIIPolicy reads and validates a PII policy file.
+func LoadPIIPolicy(path string) (*PIIPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePIIPolicy(b)
+}
+
+// ParsePIIPolicy decodes and validates a PII policy document.
+func ParsePIIPolicy(b []byte) (*PIIPolicy, error) {
+	var p PIIPolicy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("pii policy: %w", err)
+	}
+	def, err := compilePIIActions(piiActions{}, p.Default)
+	if err != nil {
+		return nil, fmt.Errorf("pii policy: default: %w", err)
+	}
+	p.def = def
+	p.routes = make(map[string]piiActions, len(p.Routes))
+	for route, m := range p.Routes {
+		if p.routes[route], err = compilePIIActions(def, m); err != nil {
+			return nil, fmt.Errorf("pii policy: route %s: %w", route, err)
+		}
+	}
+	return &p, nil
+}
+
+func compilePIIActions(base piiActions, m map[string]string) (piiActions, error) {
+next:
+	for name, action := range m {
+		switch action {
+		case "reject", "redact", "flag", "off":
+		default:
+			return base, fmt.Errorf("%s: unknown action %q", name, action)
+		}
+		if action == "off" {
+			action = ""
+		}
+		for i := range piiPatterns {
+			if piiPatterns[i].name == name {
+				base[i] = action
+				continue next
+			}
+		}
+		return base, fmt.Errorf("unknown pattern %q", name)
+	}
+	return base, nil
+}
+
+func (p *PIIPolicy) actions(route string) *piiActions {
+	if a, ok := p.routes[route]; ok {
+		return &a
+	}
+	return &p.def
+}
+
+// PIIScanner applies the active PIIPolicy to /predict metadata.
+type PIIScanner struct {
+	policy atomic.Pointer[PIIPolicy]
+}
+
+// NewPIIScanner returns a scanner using p.
+func NewPIIScanner(p *PIIPolicy) *PIIScanner {
+	s := &PIIScanner{}
+	s.policy.Store(p)
+	return s
+}
+
+// SetPolicy atomically replaces the policy.
+func (s *PIIScanner) SetPolicy(p *PIIPolicy) { s.policy.Store(p) }
+
+// Middleware makes DecodeValidateJSON scan metadata with the actions for
+// route.
+func (s *PIIScanner) Middleware(route string) func(http.Handler) http.Handler {
+	return WithPayloadChecks(func(w http.ResponseWriter, req *types.PredictRequest) error {
+		if p := s.policy.Load(); p != nil {
+			return scanPII(w, p.actions(route), req.Metadata)
+		}
+		return nil
+	})
+}
+
+// maxMetadataValueLen is the max tag on metadata values in
+// types.PredictRequest, in runes. A redacted value is clamped to it, since a
+// marker can be longer than the match it replaces.
+const maxMetadataValueLen = 4096
+
+func scanPII(w http.ResponseWriter, actions *piiActions, metadata map[string]string) error {
+	var rejectKey, rejectPattern string
+	flagged := false
+	for k, v := range metadata {
+		for i := range piiPatterns {
+			pat, action := &piiPatterns[i], actions[i]
+			if action == "" || !pat.hint(v) {
+				continue
+			}
+			switch action {
+			case "redact":
+				v = clampRunes(pat.redact(v), maxMetadataValueLen)
+				metadata[k] = v
+			case "reject":
+				if (rejectKey == "" || k < rejectKey) && pat.find(v) {
+					rejectKey, rejectPattern = k, pat.name
+				}
+			case "flag":
+				if !flagged && pat.find(v) {
+					flagged = true
+				}
+			}
+		}
+	}
+	if rejectKey != "" {
+		return &Error{Status: http.StatusBadRequest, Code: "pii_detected", Field: "metadata." + rejectKey, Message: rejectPattern + " not allowed in metadata", args: []string{rejectPattern}}
+	}
+	if flagged {
+		addFlag(w, "pii")
+	}
+	return nil
+}
+
+// clampRunes cuts s to at most n runes.
+func clampRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+func (p *piiPattern) find(s string) bool {
+	if p.ok == nil {
+		return p.re.MatchString(s)
+	}
+	for _, m := range p.re.FindAllString(s, -1) {
+		if p.ok(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *piiPattern) redact(s string) string {
+	return p.re.ReplaceAllStringFunc(s, func(m string) string {
+		if p.ok != nil && !p.ok(m) {
+			return m
+		}
+		return "[REDACTED:" + p.name + "]"
+	})
+}
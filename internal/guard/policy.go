@@ -0,0 +1,58 @@
+package guard
+
+import (
+	"context"
+	"net/http"
+)
+
+// Policy overrides the global guard settings for one request, e.g. with a
+// tenant's contract. Nil fields fall back to ActiveRules and ActiveBounds.
+type Policy struct {
+	Rules  *Rules
+	Bounds *Bounds
+}
+
+type ctxPolicyKey struct{}
+
+// WithPolicy returns a copy of ctx carrying p for DecodeValidateJSON.
+func WithPolicy(ctx context.Context, p *Policy) context.Context {
+	return context.WithValue(ctx, ctxPolicyKey{}, p)
+}
+
+// PolicyFrom returns the policy installed on ctx, if any.
+func PolicyFrom(ctx context.Context) *Policy {
+	p, _ := ctx.Value(ctxPolicyKey{}).(*Policy)
+	return p
+}
+
+// effective resolves the rules and bounds for r once, so a request is
+// validated under a single version even if a reload lands mid-request.
+func effective(r *http.Request) (*Rules, *Bounds) {
+	rules, bounds := ActiveRules(), ActiveBounds()
+	if p := PolicyFrom(r.Context()); p != nil {
+		if p.Rules != nil {
+			rules = p.Rules
+		}
+		if p.Bounds != nil {
+			bounds = p.Bounds
+		}
+	}
+	return rules, bounds
+}
+
+// checkMetadataKeys enforces Rules.AllowedMetadataKeys on decoded metadata.
+func checkMetadataKeys(rules *Rules, metadata map[string]string) error {
+	if len(rules.AllowedMetadataKeys) == 0 {
+		return nil
+	}
+next:
+	for k := range metadata {
+		for _, allowed := range rules.AllowedMetadataKeys {
+			if k == allowed {
+				continue next
+			}
+		}
+		return &Error{Status: http.StatusBadRequest, Code: "metadata_key_not_allowed", Field: "metadata." + k, Message: "metadata key not allowed"}
+	}
+	return nil
+}
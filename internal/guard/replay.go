@@ -0,0 +1,164 @@
+package guard
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReplayStore remembers keys for a TTL. It is the extension point for backing
+// replay protection with a shared store.
+type ReplayStore interface {
+	// SeenOrAdd reports whether key is already present and unexpired, and
+	// records it with the given TTL otherwise. It must be atomic per key, so
+	// of two concurrent duplicates only one is admitted.
+	SeenOrAdd(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Forget drops key, releasing a request that was recorded but then
+	// failed, so its retry is not taken for a replay.
+	Forget(ctx context.Context, key string) error
+}
+
+// ReplayGuard is a Stage rejecting payloads already accepted within Window.
+// A key is recorded when the payload passes the stage and forgotten again if
+// the request then fails, in a later stage or the handler.
+type ReplayGuard struct {
+	Store  ReplayStore
+	Window time.Duration
+	// ByBody keys on a hash of the raw body instead of the
+	// (user_id, session_id, timestamp) tuple.
+	ByBody bool
+	// FailOpen accepts requests when the store errors; by default they get 503.
+	FailOpen bool
+}
+
+var errReplay = &Error{Status: http.StatusConflict, Code: "replay", Message: "duplicate request within replay window"}
+
+// Check implements Stage.
+func (g *ReplayGuard) Check(r *http.Request, body []byte, scan *PredictScan) error {
+	key := g.key(body, scan)
+	seen, err := g.Store.SeenOrAdd(r.Context(), key, g.Window)
+	if err != nil {
+		if g.FailOpen {
+			return nil
+		}
+		return &Error{Status: http.StatusServiceUnavailable, Code: "replay_unavailable", Message: "replay store unavailable"}
+	}
+	if seen {
+		return errReplay
+	}
+	OnFailure(r, func() { _ = g.Store.Forget(context.WithoutCancel(r.Context()), key) })
+	return nil
+}
+
+// key hashes the identifying bytes so every store entry has a fixed size.
+// Identifiers are taken decoded, so re-escaping a character does not make a
+// new key.
+func (g *ReplayGuard) key(body []byte, scan *PredictScan) string {
+	h := sha256.New()
+	if g.ByBody {
+		h.Write(body)
+	} else {
+		h.Write([]byte(scan.User))
+		h.Write([]byte{0})
+		h.Write([]byte(scan.Session))
+		h.Write([]byte{0})
+		h.Write(strconv.AppendInt(nil, scan.Timestamp, 10))
+	}
+	var sum [sha256.Size]byte
+	return string(h.Sum(sum[:0])[:16])
+}
+
+// MemoryReplayStore is an in-process ReplayStore holding a bounded number of
+// keys. Entries expire after their TTL; when full, the oldest entry is
+// evicted early, which shortens the effective window under extreme load
+// rather than growing memory. Memory grows with the keys held, not the bound.
+type MemoryReplayStore struct {
+	mu      sync.Mutex
+	max     int
+	seq     uint64
+	entries map[string]replayEntry
+	order   []replaySlot // insertion order from head on, a queue
+	head    int
+}
+
+type replayEntry struct {
+	exp time.Time
+	seq uint64
+}
+
+// replaySlot remembers which insertion of key it belongs to, so popping a
+// stale slot never drops a newer entry for the same key.
+type replaySlot struct {
+	key string
+	seq uint64
+}
+
+// NewMemoryReplayStore returns a store bounded to maxEntries keys.
+func NewMemoryReplayStore(maxEntries int) *MemoryReplayStore {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &MemoryReplayStore{max: maxEntries, entries: make(map[string]replayEntry)}
+}
+
+// SeenOrAdd implements ReplayStore.
+func (s *MemoryReplayStore) SeenOrAdd(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Entries share a TTL per guard, so insertion order is expiry order.
+	for s.size() > 0 {
+		slot := s.order[s.head]
+		if e, ok := s.entries[slot.key]; ok && e.seq == slot.seq && e.exp.After(now) {
+			break
+		}
+		s.pop()
+	}
+	if e, ok := s.entries[key]; ok && e.exp.After(now) {
+		return true, nil
+	}
+	if s.size() == s.max {
+		s.pop()
+	}
+	s.seq++
+	s.entries[key] = replayEntry{exp: now.Add(ttl), seq: s.seq}
+	s.order = append(s.order, replaySlot{key: key, seq: s.seq})
+	return false, nil
+}
+
+// Forget implements ReplayStore. The key's queue slot stays until it is
+// popped, and the seq check then skips it.
+func (s *MemoryReplayStore) Forget(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryReplayStore) size() int { return len(s.order) - s.head }
+
+func (s *MemoryReplayStore) pop() {
+	slot := s.order[s.head]
+	if e, ok := s.entries[slot.key]; ok && e.seq == slot.seq {
+		delete(s.entries, slot.key)
+	}
+	s.order[s.head] = replaySlot{}
+	s.head++
+	// Reclaim the popped prefix once it is at least half the queue.
+	if s.head*2 >= len(s.order) {
+		n := copy(s.order, s.order[s.head:])
+		clear(s.order[n:])
+		s.order, s.head = s.order[:n], 0
+	}
+}
+
+// Len reports the number of keys held.
+func (s *MemoryReplayStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
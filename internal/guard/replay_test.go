@@ -0,0 +1,100 @@
+package guard
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestReplayGuard_RejectsDuplicateTuple(t *testing.T) {
+	g := &ReplayGuard{Store: NewMemoryReplayStore(16), Window: time.Minute}
+	h := WithStages(g)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		_ = DecodeValidateJSON(w, r, &req, nil)
+	}))
+
+	send := func(body string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body))))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`))
+	// Same tuple, different body.
+	assert.Equal(t, http.StatusConflict, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[2]}`))
+	assert.Equal(t, http.StatusOK, send(`{"user_id":"u","session_id":"s","timestamp":2,"features":[1]}`))
+	// Invalid payloads are never recorded.
+	assert.Equal(t, http.StatusBadRequest, send(`{"user_id":"v","session_id":"s","timestamp":1}`))
+	assert.Equal(t, http.StatusOK, send(`{"user_id":"v","session_id":"s","timestamp":1,"features":[1]}`))
+	// Identifiers are compared decoded.
+	assert.Equal(t, http.StatusConflict, send(`{"user_id":"\u0075","session_id":"s","timestamp":1,"features":[1]}`))
+}
+
+func TestReplayGuard_ForgetsFailedRequests(t *testing.T) {
+	g := &ReplayGuard{Store: NewMemoryReplayStore(16), Window: time.Minute}
+	reject := StageFunc(func(r *http.Request, _ []byte, scan *PredictScan) error {
+		if scan.Timestamp == 2 {
+			return &Error{Status: http.StatusTooManyRequests, Code: "rate_limited", Message: "rate limit exceeded"}
+		}
+		return nil
+	})
+	h := WithStages(g, reject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		if DecodeValidateJSON(w, r, &req, nil) != nil {
+			return
+		}
+		if req.Features[0] < 0 {
+			http.Error(w, "scoring failed", http.StatusInternalServerError)
+		}
+	}))
+
+	send := func(body string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body))))
+		return rr.Code
+	}
+	// Failing in the handler, then retried.
+	assert.Equal(t, http.StatusInternalServerError, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[-1]}`))
+	assert.Equal(t, http.StatusOK, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`))
+	assert.Equal(t, http.StatusConflict, send(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`))
+	// Rejected by a later stage, then retried.
+	assert.Equal(t, http.StatusTooManyRequests, send(`{"user_id":"u","session_id":"s","timestamp":2,"features":[1]}`))
+	assert.Equal(t, 1, g.Store.(*MemoryReplayStore).Len())
+}
+
+func TestMemoryReplayStore_TTLAndCapacity(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	s := NewMemoryReplayStore(2)
+	ctx := context.Background()
+	seen, _ := s.SeenOrAdd(ctx, "a", time.Second)
+	assert.False(t, seen)
+	seen, _ = s.SeenOrAdd(ctx, "a", time.Second)
+	assert.True(t, seen)
+
+	now = now.Add(2 * time.Second)
+	seen, _ = s.SeenOrAdd(ctx, "a", time.Second)
+	assert.False(t, seen, "expired")
+
+	_, _ = s.SeenOrAdd(ctx, "b", time.Second)
+	_, _ = s.SeenOrAdd(ctx, "c", time.Second)
+	assert.Equal(t, 2, s.Len())
+	seen, _ = s.SeenOrAdd(ctx, "b", time.Second)
+	assert.True(t, seen)
+
+	assert.NoError(t, s.Forget(ctx, "b"))
+	seen, _ = s.SeenOrAdd(ctx, "b", time.Second)
+	assert.False(t, seen, "forgotten")
+	// The forgotten insertion's slot is dropped without touching the new one.
+	_, _ = s.SeenOrAdd(ctx, "d", time.Second)
+	seen, _ = s.SeenOrAdd(ctx, "b", time.Second)
+	assert.True(t, seen)
+}
@@ -0,0 +1,135 @@
+package guard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync/atomic"
+)
+
+// Rules are the limits enforced by the body reader and the raw scanner.
+// They can only tighten the struct tags on types.PredictRequest, which the
+// handler still validates after decoding.
+type Rules struct {
+	MaxPayloadSize  int `json:"max_payload_size"`
+	MaxUserIDLen    int `json:"max_user_id_len"`
+	MaxSessionIDLen int `json:"max_session_id_len"`
+	MinFeatures     int `json:"min_features"`
+	MaxFeatures     int `json:"max_features"`
+	// AllowedMetadataKeys, when set, rejects any other metadata key.
+	AllowedMetadataKeys []string `json:"allowed_metadata_keys,omitempty"`
+	// UnknownFields sets the unknown-field policy ("allow", "reject" or
+	// "strip") per decoded Go type name, e.g. "PredictRequest", with "*"
+	// for any other type.
+	UnknownFields map[string]string `json:"unknown_fields,omitempty"`
+
+	Timestamp TimestampRules  `json:"timestamp"`
+	Charset   CharsetRules    `json:"charset"`
+	Structure StructureLimits `json:"structure"`
+	// Fields sets null handling and defaults per PredictRequest field.
+	Fields map[string]FieldPolicy `json:"fields,omitempty"`
+	// Constraints are cross-field rules checked after decoding.
+	Constraints []Constraint `json:"constraints,omitempty"`
+
+	fields fieldDefaults
+}
+
+// DefaultRules are the limits used until SetRules is called.
+var DefaultRules = Rules{
+	MaxPayloadSize:  MaxPayloadSize,
+	MaxUserIDLen:    64,
+	MaxSessionIDLen: 64,
+	MinFeatures:     1,
+	MaxFeatures:     16384,
+	Structure:       DefaultStructureLimits,
+}
+
+var activeRules atomic.Pointer[Rules]
+
+func init() {
+	r := DefaultRules
+	activeRules.Store(&r)
+}
+
+// ActiveRules returns the rules currently in force. Callers must not modify it.
+func ActiveRules() *Rules { return activeRules.Load() }
+
+// SetRules atomically replaces the active rules. Requests already being
+// validated finish under the rules they started with.
+func SetRules(r *Rules) { activeRules.Store(r) }
+
+// LoadRules reads and validates a rules file.
+func LoadRules(path string) (*Rules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(b)
+}
+
+// ParseRules decodes a rules document. Fields left out keep their DefaultRules value.
+func ParseRules(b []byte) (*Rules, error) {
+	return ParseRulesOver(&DefaultRules, b)
+}
+
+// ParseRulesOver decodes a rules document layered over base, which is left
+// untouched: fields left out keep base's value, objects such as unknown_fields
+// and fields merge key by key, and lists such as constraints replace base's.
+func ParseRulesOver(base *Rules, b []byte) (*Rules, error) {
+	// Decoding reuses maps and slice arrays, so give r its own.
+	r := *base
+	r.AllowedMetadataKeys = slices.Clone(base.AllowedMetadataKeys)
+	r.UnknownFields = maps.Clone(base.UnknownFields)
+	r.Fields = maps.Clone(base.Fields)
+	r.Constraints = slices.Clone(base.Constraints)
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+	return &r, nil
+}
+
+// Validate checks that the limits are usable and no looser than DefaultRules,
+// which mirror the struct tags: a looser limit would have no effect.
+func (r *Rules) Validate() error {
+	switch {
+	case r.MaxPayloadSize <= 0 || r.MaxPayloadSize > DefaultRules.MaxPayloadSize:
+		return fmt.Errorf("max_payload_size must be in [1, %d]", DefaultRules.MaxPayloadSize)
+	case r.MaxUserIDLen < 1 || r.MaxUserIDLen > DefaultRules.MaxUserIDLen:
+		return fmt.Errorf("max_user_id_len must be in [1, %d]", DefaultRules.MaxUserIDLen)
+	case r.MaxSessionIDLen < 1 || r.MaxSessionIDLen > DefaultRules.MaxSessionIDLen:
+		return fmt.Errorf("max_session_id_len must be in [1, %d]", DefaultRules.MaxSessionIDLen)
+	case r.MinFeatures < 1 || r.MaxFeatures < r.MinFeatures:
+		return errors.New("features bounds must satisfy 1 <= min <= max")
+	case r.MaxFeatures > DefaultRules.MaxFeatures:
+		return fmt.Errorf("max_features must be <= %d", DefaultRules.MaxFeatures)
+	}
+	for i := range r.Constraints {
+		if err := r.Constraints[i].compile(); err != nil {
+			return err
+		}
+	}
+	if err := r.compileFields(); err != nil {
+		return err
+	}
+	if err := r.Structure.validate(); err != nil {
+		return err
+	}
+	for typ, p := range r.UnknownFields {
+		if !validUnknownPolicy(p) {
+			return fmt.Errorf("unknown_fields %s: unknown policy %q", typ, p)
+		}
+	}
+	if err := r.Charset.UserID.validate("user_id"); err != nil {
+		return err
+	}
+	if err := r.Charset.SessionID.validate("session_id"); err != nil {
+		return err
+	}
+	return r.Timestamp.validate()
+}
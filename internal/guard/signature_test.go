@@ -0,0 +1,55 @@
+package guard
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	keys, err := ParseSigningKeys([]byte(`{"tolerance": "1m", "keys": [
+		{"id": "old", "secret": "0123456789abcdef-old"},
+		{"id": "new", "secret": "0123456789abcdef-new"}]}`))
+	assert.NoError(t, err)
+	v := NewSignatureVerifier(keys)
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		_ = DecodeValidateJSON(w, r, &req, nil)
+	}))
+
+	body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`)
+	send := func(sig string, body []byte) (int, string) {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		if sig != "" {
+			r.Header.Set(SignatureHeader, sig)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code, rr.Body.String()
+	}
+
+	code, _ := send(Sign(keys.Keys[0], now, body), body)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = send(Sign(keys.Keys[1], now.Add(-30*time.Second), body), body)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, msg := send("", body)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Contains(t, msg, "signature_missing")
+	_, msg = send(Sign(keys.Keys[0], now.Add(-2*time.Minute), body), body)
+	assert.Contains(t, msg, "signature_expired")
+	_, msg = send(Sign(keys.Keys[0], now, body), append(body, ' '))
+	assert.Contains(t, msg, "signature_invalid")
+	_, msg = send(Sign(SigningKey{ID: "new", Secret: "0123456789abcdef-old"}, now, body), body)
+	assert.Contains(t, msg, "signature_invalid", "key id pins the key")
+}
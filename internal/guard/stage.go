@@ -0,0 +1,158 @@
+package guard
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// Stage is an optional check DecodeValidateJSON runs on a /predict payload
+// once it has passed the scanner, decoding and validation. Returning an error
+// rejects the request; an *Error controls the status and code. A stage that
+// records the request registers an OnFailure undo, so only requests that are
+// served end up recorded.
+type Stage interface {
+	Check(r *http.Request, body []byte, scan *PredictScan) error
+}
+
+// StageFunc adapts a function to Stage.
+type StageFunc func(r *http.Request, body []byte, scan *PredictScan) error
+
+func (f StageFunc) Check(r *http.Request, body []byte, scan *PredictScan) error {
+	return f(r, body, scan)
+}
+
+type ctxStagesKey struct{}
+
+// WithStages returns middleware that makes DecodeValidateJSON run stages, in
+// order, after any installed by enclosing middleware. The outermost one runs
+// the OnFailure undos when the response status is 400 or more, or the
+// handler panics.
+func WithStages(stages ...Stage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			prev := stagesFrom(r.Context())
+			all := make([]Stage, 0, len(prev)+len(stages))
+			all = append(append(all, prev...), stages...)
+			ctx := context.WithValue(r.Context(), ctxStagesKey{}, all)
+			if outcomeFrom(ctx) != nil {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			o := &stageOutcome{}
+			sw := &statusWriter{ResponseWriter: w}
+			served := false
+			defer func() {
+				if !served || sw.status >= http.StatusBadRequest {
+					for i := len(o.undo) - 1; i >= 0; i-- {
+						o.undo[i]()
+					}
+				}
+			}()
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(ctx, ctxOutcomeKey{}, o)))
+			served = true
+		})
+	}
+}
+
+func stagesFrom(ctx context.Context) []Stage {
+	s, _ := ctx.Value(ctxStagesKey{}).([]Stage)
+	return s
+}
+
+// stageOutcome collects the OnFailure undos of one request.
+type stageOutcome struct {
+	undo []func()
+}
+
+type ctxOutcomeKey struct{}
+
+func outcomeFrom(ctx context.Context) *stageOutcome {
+	o, _ := ctx.Value(ctxOutcomeKey{}).(*stageOutcome)
+	return o
+}
+
+// OnFailure registers undo to run, in reverse order of registration, if the
+// request ends with an error status: rejected by a later stage, or failing in
+// the handler. It is a no-op outside WithStages. r must be the request the
+// stage was given.
+func OnFailure(r *http.Request, undo func()) {
+	if o := outcomeFrom(r.Context()); o != nil {
+		o.undo = append(o.undo, undo)
+	}
+}
+
+// statusWriter records the response status.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// RawCheck inspects the body right after DecodeValidateJSON reads it into the
+// pooled buffer and before any JSON parsing. body must not be retained.
+type RawCheck func(r *http.Request, body []byte) error
+
+type ctxRawChecksKey struct{}
+
+// WithRawChecks returns middleware that makes DecodeValidateJSON run checks,
+// in order, on the raw body.
+func WithRawChecks(checks ...RawCheck) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, withRawChecks(r, checks...))
+		})
+	}
+}
+
+func withRawChecks(r *http.Request, checks ...RawCheck) *http.Request {
+	prev := rawChecksFrom(r.Context())
+	all := make([]RawCheck, 0, len(prev)+len(checks))
+	all = append(append(all, prev...), checks...)
+	return r.WithContext(context.WithValue(r.Context(), ctxRawChecksKey{}, all))
+}
+
+func rawChecksFrom(ctx context.Context) []RawCheck {
+	c, _ := ctx.Value(ctxRawChecksKey{}).([]RawCheck)
+	return c
+}
+
+// PayloadCheck inspects, and may rewrite in place, a decoded /predict
+// payload before validation and the handler see it. w is only for adding
+// FlagsHeader entries.
+type PayloadCheck func(w http.ResponseWriter, req *types.PredictRequest) error
+
+type ctxPayloadChecksKey struct{}
+
+// WithPayloadChecks returns middleware that makes DecodeValidateJSON run
+// checks, in order, on the decoded payload.
+func WithPayloadChecks(checks ...PayloadCheck) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			prev := payloadChecksFrom(r.Context())
+			all := make([]PayloadCheck, 0, len(prev)+len(checks))
+			all = append(append(all, prev...), checks...)
+			r = r.WithContext(context.WithValue(r.Context(), ctxPayloadChecksKey{}, all))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func payloadChecksFrom(ctx context.Context) []PayloadCheck {
+	c, _ := ctx.Value(ctxPayloadChecksKey{}).([]PayloadCheck)
+	return c
+}
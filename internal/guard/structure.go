@@ -0,0 +1,110 @@
+package guard
+
+import (
+	"errors"
+	"net/http"
+)
+
+// StructureLimits bound the shape of any body DecodeValidateJSON accepts,
+// checked in one pass before the scanner or decoder looks at it. Lengths
+// are in raw bytes, escapes included.
+type StructureLimits struct {
+	MaxDepth      int `json:"max_depth"`       // nested objects and arrays, at most 256
+	MaxValues     int `json:"max_values"`      // values in the whole body, keys excluded
+	MaxObjectKeys int `json:"max_object_keys"` // keys in any one object
+	MaxStringLen  int `json:"max_string_len"`  // one string, key or value
+	MaxNumberLen  int `json:"max_number_len"`  // one number literal
+}
+
+// DefaultStructureLimits fit types.PredictRequest with room to spare.
+var DefaultStructureLimits = StructureLimits{
+	MaxDepth:      16,
+	MaxValues:     20000,
+	MaxObjectKeys: 256,
+	MaxStringLen:  24576, // 4096 runes, each escaped as \uXXXX
+	MaxNumberLen:  40,
+}
+
+const maxStructureDepth = 256
+
+func (l *StructureLimits) validate() error {
+	if l.MaxDepth < 1 || l.MaxDepth > maxStructureDepth {
+		return errors.New("structure: max_depth must be in [1, 256]")
+	}
+	if l.MaxValues < 1 || l.MaxObjectKeys < 1 || l.MaxStringLen < 1 || l.MaxNumberLen < 1 {
+		return errors.New("structure: limits must be >= 1")
+	}
+	return nil
+}
+
+func structureError(code, msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: code, Message: msg}
+}
+
+var (
+	errMaxDepth      = structureError("max_depth", "nesting too deep")
+	errMaxValues     = structureError("max_values", "too many values")
+	errMaxObjectKeys = structureError("max_object_keys", "too many keys in one object")
+	errMaxStringLen  = structureError("max_string_len", "string too long")
+	errMaxNumberLen  = structureError("max_number_len", "number literal too long")
+)
+
+// checkStructure enforces l on buf without allocating. It does not check
+// that buf is well-formed JSON; the scanner and decoder do that.
+func checkStructure(buf []byte, l *StructureLimits) error {
+	var keys [maxStructureDepth + 1]int32 // per open object, by depth
+	depth, values := 0, 0
+	i := 0
+	for i < len(buf) {
+		switch c := buf[i]; {
+		case c == '"':
+			end := skipString(buf, i)
+			if end > len(buf) {
+				end = len(buf)
+			}
+			if end-i-2 > l.MaxStringLen {
+				return errMaxStringLen
+			}
+			i = end
+			if j := skipWS(buf, i); j < len(buf) && buf[j] == ':' {
+				if keys[depth]++; int(keys[depth]) > l.MaxObjectKeys {
+					return errMaxObjectKeys
+				}
+				continue
+			}
+			values++
+		case c == '{' || c == '[':
+			if depth++; depth > l.MaxDepth {
+				return errMaxDepth
+			}
+			keys[depth] = 0
+			values++
+			i++
+		case c == '}' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case c == '-' || '0' <= c && c <= '9':
+			start := i
+			for i < len(buf) && (buf[i] == '-' || buf[i] == '+' || buf[i] == '.' || buf[i] == 'e' || buf[i] == 'E' || '0' <= buf[i] && buf[i] <= '9') {
+				i++
+			}
+			if i-start > l.MaxNumberLen {
+				return errMaxNumberLen
+			}
+			values++
+		case 'a' <= c && c <= 'z': // true, false, null
+			for i < len(buf) && 'a' <= buf[i] && buf[i] <= 'z' {
+				i++
+			}
+			values++
+		default:
+			i++
+		}
+		if values > l.MaxValues {
+			return errMaxValues
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package guard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStructure(t *testing.T) {
+	l := StructureLimits{MaxDepth: 3, MaxValues: 10, MaxObjectKeys: 3, MaxStringLen: 8, MaxNumberLen: 6}
+
+	assert.NoError(t, checkStructure([]byte(`{"a": [1, -2.5e3, "x"], "b": {"c": true}, "d": null}`), &l))
+	// Braces and colons inside strings are not structure.
+	assert.NoError(t, checkStructure([]byte(`{"a": "[[[{:", "b": "\"}]]]"}`), &l))
+
+	for body, want := range map[string]*Error{
+		`{"a": [[[1]]]}`:                      errMaxDepth,
+		`[` + strings.Repeat(`1,`, 10) + `1]`: errMaxValues,
+		`{"a":1,"b":2,"c":3,"d":4}`:           errMaxObjectKeys,
+		`{"a": "123456789"}`:                  errMaxStringLen,
+		`{"123456789": 1}`:                    errMaxStringLen,
+		`{"a": 1.234567}`:                     errMaxNumberLen,
+		strings.Repeat("[", 100000):           errMaxDepth,
+	} {
+		assert.Equal(t, want, checkStructure([]byte(body), &l), body)
+	}
+
+	// Keys are counted per object, not across nesting levels.
+	assert.NoError(t, checkStructure([]byte(`{"a":{"x":1,"y":2,"z":3},"b":1,"c":2}`), &l))
+
+	def := DefaultStructureLimits
+	assert.NoError(t, def.validate())
+	_, err := ParseRules([]byte(`{"structure": {"max_depth": 1000}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/example/jsoninputguard/internal/types"
+	"github.com/example/jsoninputguard/internal/validate"
+)
+
+// scanTagSet mirrors the domain tags declared on a payload type in the raw
+// scanner, using the validate package's predicates so the tag and the scan
+// agree. Only uuid4_lower on the identifiers, epoch_ms on the timestamp and
+// the length of feature_vector are mirrored: the scanner does not read
+// feature values or metadata, so finite, unit_interval, metadata_key and the
+// per-value part of feature_vector are left to the validator after decoding.
+type scanTagSet struct {
+	userID, sessionID idTag
+	timestampEpochMS  bool
+	maxFeatures       int // from feature_vector=N; 0 if absent
+}
+
+type idTag struct {
+	name  string
+	check func([]byte) bool
+}
+
+// scanTags are read once from types.PredictRequest.
+var scanTags = readScanTags(reflect.TypeOf(types.PredictRequest{}))
+
+func readScanTags(t reflect.Type) scanTagSet {
+	var set scanTagSet
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		tag, _, _ := strings.Cut(f.Tag.Get("validate"), ",dive") // element tags are not mirrored
+		for _, rule := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(rule, "=")
+			switch {
+			case rule == "uuid4_lower" && name == "user_id":
+				set.userID = idTag{rule, validate.IsUUID4Lower[[]byte]}
+			case rule == "uuid4_lower" && name == "session_id":
+				set.sessionID = idTag{rule, validate.IsUUID4Lower[[]byte]}
+			case rule == "epoch_ms" && name == "timestamp":
+				set.timestampEpochMS = true
+			case rule == "feature_vector" && name == "features":
+				set.maxFeatures, _ = validate.FeatureVectorParam(param)
+			}
+		}
+	}
+	return set
+}
+
+func tagError(field, tag string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: tag, Field: field, Message: "fails " + tag}
+}
+
+// checkID applies the field's tag to its raw JSON string contents, decoding
+// them first only when they contain escapes.
+func (t idTag) checkID(field string, raw []byte) error {
+	if t.check == nil {
+		return nil
+	}
+	s := raw
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		var decoded string
+		if err := json.Unmarshal(append(append([]byte{'"'}, raw...), '"'), &decoded); err != nil {
+			return tagError(field, t.name)
+		}
+		s = []byte(decoded)
+	}
+	if !t.check(s) {
+		return tagError(field, t.name)
+	}
+	return nil
+}
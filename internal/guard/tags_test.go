@@ -0,0 +1,29 @@
+package guard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanTagsMirrorValidateTags(t *testing.T) {
+	assert.Equal(t, 16384, scanTags.maxFeatures, "read from types.PredictRequest")
+
+	type payload struct {
+		UserID    string    `json:"user_id" validate:"required,uuid4_lower"`
+		Timestamp int64     `json:"timestamp" validate:"epoch_ms"`
+		Features  []float32 `json:"features" validate:"feature_vector=2,dive,finite"`
+	}
+	set := readScanTags(reflect.TypeOf(payload{}))
+	assert.True(t, set.timestampEpochMS)
+	assert.Equal(t, 2, set.maxFeatures)
+	assert.Nil(t, set.sessionID.check)
+
+	id := "9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b"
+	assert.NoError(t, set.userID.checkID("user_id", []byte(id)))
+	// Escapes are decoded before the check, as the validator sees them.
+	assert.NoError(t, set.userID.checkID("user_id", []byte(`\u0039`+id[1:])))
+	assert.Equal(t, &Error{Status: 400, Code: "uuid4_lower", Field: "user_id", Message: "fails uuid4_lower"},
+		set.userID.checkID("user_id", []byte("not-a-uuid")))
+}
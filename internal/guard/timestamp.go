@@ -0,0 +1,133 @@
+package guard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Now is the clock timestamp rules are checked against. Tests and offline
+// replays may replace it before serving.
+var Now = time.Now
+
+// Duration is a time.Duration written in JSON as a string such as "5m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// TimestampRules constrain the timestamp field beyond "> 0".
+type TimestampRules struct {
+	// Unit is "s", "ms", "ns", "auto" or empty. Empty only requires a positive
+	// value. "auto" picks the single unit that places the value inside the
+	// accepted window and rejects the value if none or several do.
+	Unit string `json:"unit,omitempty"`
+	// MaxAge rejects timestamps older than now-MaxAge. Zero disables it.
+	MaxAge Duration `json:"max_age,omitempty"`
+	// MaxFutureSkew rejects timestamps later than now+MaxFutureSkew. Zero disables it.
+	MaxFutureSkew Duration `json:"max_future_skew,omitempty"`
+}
+
+var timestampUnits = []struct {
+	name string
+	mult int64
+}{{"s", int64(time.Second)}, {"ms", int64(time.Millisecond)}, {"ns", 1}}
+
+// Without age or skew limits, auto-detection needs some window to place a
+// value in.
+var (
+	plausibleFrom = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	plausibleTo   = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func (t *TimestampRules) validate() error {
+	switch t.Unit {
+	case "", "s", "ms", "ns", "auto":
+	default:
+		return fmt.Errorf("timestamp: unknown unit %q", t.Unit)
+	}
+	if t.MaxAge < 0 || t.MaxFutureSkew < 0 {
+		return errors.New("timestamp: max_age and max_future_skew must be >= 0")
+	}
+	if t.Unit == "" && (t.MaxAge > 0 || t.MaxFutureSkew > 0) {
+		return errors.New("timestamp: max_age and max_future_skew need a unit")
+	}
+	return nil
+}
+
+func timestampError(code, msg string, args ...string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: code, Field: "timestamp", Message: msg, args: args}
+}
+
+// check applies the rules to a parsed, positive timestamp.
+func (t *TimestampRules) check(n int64) error {
+	if t.Unit == "" {
+		return nil
+	}
+	now := Now()
+	from, to := plausibleFrom, plausibleTo
+	if t.MaxAge > 0 {
+		from = now.Add(-time.Duration(t.MaxAge))
+	}
+	if t.MaxFutureSkew > 0 {
+		to = now.Add(time.Duration(t.MaxFutureSkew))
+	}
+
+	if t.Unit == "auto" {
+		found := 0
+		for _, u := range timestampUnits {
+			if n > math.MaxInt64/u.mult {
+				continue
+			}
+			at := time.Unix(0, n*u.mult)
+			if !at.Before(from) && !at.After(to) {
+				found++
+			}
+		}
+		switch found {
+		case 1:
+			return nil
+		case 0:
+			return timestampError("timestamp_unit", "no unit places the timestamp in the accepted window")
+		default:
+			return timestampError("timestamp_unit", "timestamp unit is ambiguous")
+		}
+	}
+
+	var mult int64
+	for _, u := range timestampUnits {
+		if u.name == t.Unit {
+			mult = u.mult
+		}
+	}
+	if n > math.MaxInt64/mult {
+		return timestampError("timestamp_range", "timestamp out of range for unit "+t.Unit)
+	}
+	at := time.Unix(0, n*mult)
+	if t.MaxAge > 0 && at.Before(from) {
+		age := time.Duration(t.MaxAge).String()
+		return timestampError("timestamp_stale", "timestamp older than "+age, age)
+	}
+	if t.MaxFutureSkew > 0 && at.After(to) {
+		skew := time.Duration(t.MaxFutureSkew).String()
+		return timestampError("timestamp_future", "timestamp more than "+skew+" in the future", skew)
+	}
+	return nil
+}
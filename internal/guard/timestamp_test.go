@@ -0,0 +1,67 @@
+package guard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardPredictRaw_TimestampRules(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	rules := DefaultRules
+	rules.Timestamp = TimestampRules{Unit: "auto", MaxAge: Duration(5 * time.Minute), MaxFutureSkew: Duration(time.Minute)}
+	assert.NoError(t, rules.Validate())
+
+	payload := func(ts string) []byte {
+		return []byte(`{"user_id":"u","session_id":"s","timestamp":` + ts + `,"features":[1]}`)
+	}
+	codeOf := func(err error) string {
+		if ge, ok := err.(*Error); ok {
+			return ge.Code
+		}
+		return ""
+	}
+
+	assert.NoError(t, guardPredictRaw(payload("1700000000"), &rules))          // seconds
+	assert.NoError(t, guardPredictRaw(payload("1699999900000"), &rules))       // milliseconds
+	assert.NoError(t, guardPredictRaw(payload("1700000030000000000"), &rules)) // nanoseconds
+	assert.Equal(t, "timestamp_unit", codeOf(guardPredictRaw(payload("1600000000"), &rules)))
+	assert.Equal(t, "timestamp_range", codeOf(guardPredictRaw(payload("99999999999999999999"), &rules)))
+
+	rules.Timestamp.Unit = "ms"
+	assert.Equal(t, "timestamp_stale", codeOf(guardPredictRaw(payload("1699999000000"), &rules)))
+	assert.Equal(t, "timestamp_future", codeOf(guardPredictRaw(payload("1700000120000"), &rules)))
+	assert.Equal(t, "timestamp_range", codeOf(guardPredictRaw(payload("9000000000000000000"), &rules)))
+}
+
+func TestGuardPredictRaw_ContractKeysSpelledOnce(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	defer func(orig func() time.Time) { Now = orig }(Now)
+	Now = func() time.Time { return now }
+
+	rules := DefaultRules
+	rules.Timestamp = TimestampRules{Unit: "s", MaxAge: Duration(5 * time.Minute)}
+	assert.NoError(t, rules.Validate())
+
+	// encoding/json would decode each of these into Timestamp, so 5 would
+	// reach the handler past max_age.
+	for body, code := range map[string]string{
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"Timestamp":5,"features":[1]}`:      "ambiguous_key",
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"TIMESTAMP":5,"features":[1]}`:      "ambiguous_key",
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"\u0074imestamp":5,"features":[1]}`: "ambiguous_key",
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"timeſtamp":5,"features":[1]}`:      "ambiguous_key",
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"timestamp":5,"features":[1]}`:      "duplicate_key",
+		`{"user_id":"u","session_id":"s","timestamp":1700000000,"features":[1],"Features":[1,2]}`:   "ambiguous_key",
+	} {
+		err := guardPredictRaw([]byte(body), &rules)
+		if assert.IsType(t, &Error{}, err, body) {
+			assert.Equal(t, code, err.(*Error).Code, body)
+		}
+	}
+	// Keys that merely contain a contract name are not contract keys.
+	assert.NoError(t, guardPredictRaw([]byte(`{"user_id":"u","session_id":"s","timestamp":1700000000,"features":[1],"timestamps":5}`), &rules))
+}
@@ -0,0 +1,210 @@
+package guard
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Unknown-field policies, set per decoded type in Rules.UnknownFields.
+const (
+	UnknownAllow  = "allow"  // skip them, as json.Unmarshal does (default)
+	UnknownReject = "reject" // 400 unknown_fields listing them
+	UnknownStrip  = "strip"  // rewrite the raw body without them
+)
+
+func validUnknownPolicy(p string) bool {
+	return p == UnknownAllow || p == UnknownReject || p == UnknownStrip
+}
+
+// unknownFieldPolicy returns the policy for the Go type of dst, falling back
+// to the "*" entry.
+func (r *Rules) unknownFieldPolicy(t reflect.Type) string {
+	if p, ok := r.UnknownFields[t.Name()]; ok {
+		return p
+	}
+	if p, ok := r.UnknownFields["*"]; ok {
+		return p
+	}
+	return UnknownAllow
+}
+
+var knownFieldsCache sync.Map // reflect.Type -> map[string]bool
+
+// knownFields returns the top-level JSON names of struct type t. Names are
+// matched exactly, so a miscased key counts as unknown.
+func knownFields(t reflect.Type) map[string]bool {
+	if v, ok := knownFieldsCache.Load(t); ok {
+		return v.(map[string]bool)
+	}
+	known := map[string]bool{}
+	addKnownFields(t, known)
+	knownFieldsCache.Store(t, known)
+	return known
+}
+
+func addKnownFields(t reflect.Type, known map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addKnownFields(f.Type, known)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		known[name] = true
+	}
+}
+
+// applyUnknownFields enforces policy on the top-level members of the object
+// in buf. With UnknownStrip it returns buf rewritten in place, without
+// allocating, keeping only known members. Malformed input is returned
+// untouched for the scanner or decoder to report: the object is walked in
+// full before the rewrite starts.
+func applyUnknownFields(buf []byte, known map[string]bool, policy string) ([]byte, error) {
+	var unknown []string
+	strip := false
+	end, ok := members(buf, func(key []byte, _, _ int) {
+		if known[string(key)] {
+			return
+		}
+		strip = policy == UnknownStrip
+		if policy == UnknownReject {
+			unknown = append(unknown, string(key))
+		}
+	})
+	if !ok {
+		return buf, nil
+	}
+	if len(unknown) > 0 {
+		return buf, &Error{Status: http.StatusBadRequest, Code: "unknown_fields", Message: fmt.Sprintf("unknown fields: %s", strings.Join(unknown, ", ")), Fields: unknown}
+	}
+	if !strip {
+		return buf, nil
+	}
+	w := skipWS(buf, 0) + 1 // after the opening brace
+	kept := 0
+	members(buf, func(key []byte, start, end int) {
+		if !known[string(key)] {
+			return
+		}
+		if kept > 0 {
+			buf[w] = ','
+			w++
+		}
+		w += copy(buf[w:], buf[start:end])
+		kept++
+	})
+	// Keep the closing brace and anything after it for the scanner to judge.
+	w += copy(buf[w:], buf[end-1:])
+	return buf[:w], nil
+}
+
+// members calls fn with the key and [start, end) span of each top-level
+// member of the object in buf, in order, and returns the index after its
+// closing brace. It reports false, possibly after some calls, if buf does not
+// hold a well-formed object at the top level. fn may only write to buf before
+// start.
+func members(buf []byte, fn func(key []byte, start, end int)) (int, bool) {
+	i := skipWS(buf, 0)
+	if i >= len(buf) || buf[i] != '{' {
+		return 0, false
+	}
+	i = skipWS(buf, i+1)
+	if i < len(buf) && buf[i] == '}' {
+		return i + 1, true
+	}
+	for {
+		if i >= len(buf) || buf[i] != '"' {
+			return 0, false
+		}
+		start := i
+		keyEnd := skipString(buf, i)
+		if keyEnd > len(buf) {
+			return 0, false
+		}
+		i = skipWS(buf, keyEnd)
+		if i >= len(buf) || buf[i] != ':' {
+			return 0, false
+		}
+		end := skipValue(buf, skipWS(buf, i+1))
+		if end > len(buf) {
+			return 0, false
+		}
+		fn(buf[start+1:keyEnd-1], start, end)
+		switch i = skipWS(buf, end); {
+		case i >= len(buf):
+			return 0, false
+		case buf[i] == '}':
+			return i + 1, true
+		case buf[i] != ',':
+			return 0, false
+		}
+		i = skipWS(buf, i+1)
+	}
+}
+
+func skipWS(buf []byte, i int) int {
+	for i < len(buf) && (buf[i] == ' ' || buf[i] == '\n' || buf[i] == '\r' || buf[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index after the string starting at buf[i], or
+// len(buf)+1 if it is unterminated.
+func skipString(buf []byte, i int) int {
+	for i++; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(buf) + 1
+}
+
+// skipValue returns the index after the value starting at buf[i], or
+// len(buf)+1 if it is truncated.
+func skipValue(buf []byte, i int) int {
+	if i >= len(buf) {
+		return len(buf) + 1
+	}
+	switch buf[i] {
+	case '"':
+		return skipString(buf, i)
+	case '{', '[':
+		depth := 0
+		for i < len(buf) {
+			switch buf[i] {
+			case '"':
+				i = skipString(buf, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return len(buf) + 1
+	}
+	for i < len(buf) && buf[i] != ',' && buf[i] != '}' && buf[i] != ']' && buf[i] != ' ' && buf[i] != '\n' && buf[i] != '\r' && buf[i] != '\t' {
+		i++
+	}
+	return i
+}
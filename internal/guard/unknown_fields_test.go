@@ -0,0 +1,67 @@
+package guard
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestApplyUnknownFields(t *testing.T) {
+	known := knownFields(reflect.TypeOf(types.PredictRequest{}))
+	assert.True(t, known["user_id"] && known["metadata"] && known["model"])
+
+	body := ` { "extra": {"a": [1, {"b": "}"}]}, "user_id": "u\"x", "User_Id": 1,"features":[1,2] , "z": null } `
+	out, err := applyUnknownFields([]byte(body), known, UnknownStrip)
+	assert.NoError(t, err)
+	assert.Equal(t, ` {"user_id": "u\"x","features":[1,2]} `, string(out))
+
+	_, err = applyUnknownFields([]byte(body), known, UnknownReject)
+	assert.Equal(t, &Error{Status: 400, Code: "unknown_fields", Message: "unknown fields: extra, User_Id, z", Fields: []string{"extra", "User_Id", "z"}}, err)
+
+	out, err = applyUnknownFields([]byte(`{"z":1}`), known, UnknownStrip)
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(out))
+
+	// Malformed bodies are left for the scanner to reject.
+	out, err = applyUnknownFields([]byte(`{"z":[1`), known, UnknownReject)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"z":[1`, string(out))
+	for _, body := range []string{
+		`{"z":1,"user_id":"u","x":[1`,
+		`{"z":1,"user_id":"u" "x":1}`,
+	} {
+		out, err = applyUnknownFields([]byte(body), known, UnknownStrip)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(out), "not partially rewritten")
+	}
+	// Whatever follows the object is kept for the scanner to reject.
+	out, _ = applyUnknownFields([]byte(`{"z":1,"user_id":"u"}x`), known, UnknownStrip)
+	assert.Equal(t, `{"user_id":"u"}x`, string(out))
+}
+
+func TestDecodeValidateJSON_StripUnknownFields(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"unknown_fields": {"PredictRequest": "strip"}}`))
+	assert.NoError(t, err)
+	defer SetRules(ActiveRules())
+	SetRules(rules)
+
+	var seen string
+	h := WithStages(StageFunc(func(_ *http.Request, body []byte, _ *PredictScan) error {
+		seen = string(body)
+		return nil
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		_ = DecodeValidateJSON(w, r, &req, nil)
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`{"user_id":"u","password":"hunter2","session_id":"s","timestamp":1,"features":[1]}`))))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`, seen)
+}
@@ -0,0 +1,164 @@
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"reflect"
+
+	"github.com/example/jsoninputguard/internal/types"
+	"github.com/example/jsoninputguard/internal/validate"
+)
+
+// VersionHeader declares the schema version of a /predict body. Without it
+// a top-level "version" string in the body is used, and without either the
+// body is taken to be the current version.
+const VersionHeader = "X-Schema-Version"
+
+// Versions holds past versions of the /predict contract beside the current
+// one. A body declaring a past version is decoded into that version's type,
+// checked under its rules and tags, migrated to types.PredictRequest and
+// re-encoded, so the current rules, checks and stages then apply to it as to
+// any other body. Raw checks, such as signatures, see the body as sent.
+type Versions struct {
+	current string
+	past    map[string]upgrade
+}
+
+// upgrade checks buf as one past version and returns it migrated.
+type upgrade func(buf []byte, rules *Rules) ([]byte, error)
+
+// NewVersions returns a registry whose current version, the one
+// types.PredictRequest describes, is named current.
+func NewVersions(current string) *Versions {
+	return &Versions{current: current, past: map[string]upgrade{}}
+}
+
+// Current is the name of the version types.PredictRequest describes.
+func (vs *Versions) Current() string { return vs.current }
+
+// RegisterVersion adds past version name, whose bodies decode into the
+// struct T and pass its validate tags, and migrate into the current request.
+// rules bound the body as sent (structure and unknown fields); nil uses the
+// request's own rules. Register every version before serving.
+func RegisterVersion[T any](vs *Versions, name string, rules *Rules, migrate func(old *T) (*types.PredictRequest, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	vs.past[name] = func(buf []byte, reqRules *Rules) ([]byte, error) {
+		r := rules
+		if r == nil {
+			r = reqRules
+		}
+		if err := checkStructure(buf, &r.Structure); err != nil {
+			return nil, err
+		}
+		if policy := r.unknownFieldPolicy(t); policy != UnknownAllow {
+			var err error
+			if buf, err = applyUnknownFields(buf, knownFields(t), policy); err != nil {
+				return nil, err
+			}
+		}
+		var old T
+		if err := json.Unmarshal(buf, &old); err != nil {
+			return nil, err
+		}
+		if err := validate.V().Struct(&old); err != nil {
+			return nil, err
+		}
+		req, err := migrate(&old)
+		if err != nil {
+			return nil, err
+		}
+		req.Version = vs.current
+		return encodeMigrated(req)
+	}
+}
+
+// encodeMigrated encodes req with NaN features, which migrations use for
+// entries the old version lacks, as null, as clients send them.
+func encodeMigrated(req *types.PredictRequest) ([]byte, error) {
+	features := make([]*float32, len(req.Features))
+	for i := range req.Features {
+		if !math.IsNaN(float64(req.Features[i])) {
+			features[i] = &req.Features[i]
+		}
+	}
+	return json.Marshal(struct {
+		*types.PredictRequest
+		Features []*float32 `json:"features"`
+	}{req, features})
+}
+
+var errUnknownVersion = &Error{Status: http.StatusBadRequest, Code: "unknown_version", Field: "version", Message: "unknown schema version"}
+
+// migrate resolves the version of buf and returns it migrated, or nil if it
+// is the current version.
+func (vs *Versions) migrate(r *http.Request, buf []byte, rules *Rules) ([]byte, error) {
+	name := r.Header.Get(VersionHeader)
+	if name == "" {
+		name = topLevelString(buf, "version")
+	}
+	if name == "" || name == vs.current {
+		return nil, nil
+	}
+	up, ok := vs.past[name]
+	if !ok {
+		return nil, errUnknownVersion
+	}
+	return up(buf, rules)
+}
+
+// topLevelString returns the raw contents of the top-level string member key
+// of the object in buf, or "" if there is none.
+func topLevelString(buf []byte, key string) string {
+	i := skipWS(buf, 0)
+	if i >= len(buf) || buf[i] != '{' {
+		return ""
+	}
+	for i++; ; {
+		i = skipWS(buf, i)
+		if i >= len(buf) || buf[i] != '"' {
+			return ""
+		}
+		keyEnd := skipString(buf, i)
+		if keyEnd > len(buf) {
+			return ""
+		}
+		k := buf[i+1 : keyEnd-1]
+		i = skipWS(buf, keyEnd)
+		if i >= len(buf) || buf[i] != ':' {
+			return ""
+		}
+		i = skipWS(buf, i+1)
+		end := skipValue(buf, i)
+		if end > len(buf) {
+			return ""
+		}
+		if string(k) == key {
+			if buf[i] != '"' {
+				return ""
+			}
+			return string(buf[i+1 : end-1])
+		}
+		i = skipWS(buf, end)
+		if i >= len(buf) || buf[i] != ',' {
+			return ""
+		}
+		i++
+	}
+}
+
+type ctxVersionsKey struct{}
+
+// Middleware makes DecodeValidateJSON resolve the schema version of
+// /predict bodies and migrate past ones.
+func (vs *Versions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxVersionsKey{}, vs)))
+	})
+}
+
+func versionsFrom(ctx context.Context) *Versions {
+	vs, _ := ctx.Value(ctxVersionsKey{}).(*Versions)
+	return vs
+}
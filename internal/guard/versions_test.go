@@ -0,0 +1,83 @@
+package guard
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// predictV1 named its fields differently and sent features in reverse order,
+// without the third one.
+type predictV1 struct {
+	UID      string    `json:"uid" validate:"required,max=32"`
+	Session  string    `json:"session" validate:"required"`
+	TS       int64     `json:"ts" validate:"required"`
+	Features []float32 `json:"f" validate:"len=2"`
+}
+
+func TestDecodeValidateJSON_Versions(t *testing.T) {
+	vs := NewVersions("2")
+	RegisterVersion(vs, "1", nil, func(old *predictV1) (*types.PredictRequest, error) {
+		return &types.PredictRequest{UserID: old.UID, SessionID: old.Session, Timestamp: old.TS,
+			Features: []float32{old.Features[1], old.Features[0], float32(math.NaN())}}, nil
+	})
+
+	var req types.PredictRequest
+	handler := vs.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = types.PredictRequest{}
+		_ = DecodeValidateJSON(w, r, &req, nil)
+	}))
+	send := func(header, body string) (int, map[string]any) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if header != "" {
+			r.Header.Set(VersionHeader, header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		var got map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		return rr.Code, got
+	}
+
+	v1 := `{"uid":"u","session":"s","ts":1700000000,"f":[1,2]}`
+	for _, c := range []struct{ header, body string }{
+		{"1", v1},
+		{"", `{"version":"1",` + v1[1:]},
+	} {
+		code, _ := send(c.header, c.body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "u", req.UserID)
+		assert.Equal(t, "2", req.Version)
+		assert.Equal(t, []float32{2, 1}, req.Features[:2])
+		assert.True(t, math.IsNaN(float64(req.Features[2])), "missing entries migrate as null")
+	}
+
+	// The current version is read as is, declared or not.
+	current := `{"user_id":"u","session_id":"s","timestamp":1700000000,"features":[1]}`
+	code, _ := send("", current)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = send("2", current)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, got := send("1", `{"uid":"u","session":"s","ts":1700000000,"f":[1]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "f", got["field"], "old versions keep their own tags")
+
+	code, got = send("", `{"version":"0","user_id":"u"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "unknown_version", got["code"])
+}
+
+func TestTopLevelString(t *testing.T) {
+	assert.Equal(t, "3", topLevelString([]byte(` {"a":{"version":"1"}, "b":[1,"x"] ,"version" : "3"}`), "version"))
+	assert.Equal(t, "", topLevelString([]byte(`{"version":3}`), "version"))
+	assert.Equal(t, "", topLevelString([]byte(`{"a":"version"}`), "version"))
+	assert.Equal(t, "", topLevelString([]byte(`{"version":"1`), "version"))
+}
@@ -0,0 +1,55 @@
+package limit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled at a fixed rate up to a burst size.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket allowing rate requests per second with
+// bursts of up to burst. A burst below 1 is raised to 1.
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Allow takes a token if one is available at now. Otherwise it reports how
+// long until one will be.
+func (b *Bucket) Allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		if dt := now.Sub(b.last).Seconds(); dt > 0 {
+			b.tokens = math.Min(b.burst, b.tokens+dt*b.rate)
+		}
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Same reports whether b was built with the given parameters, so a reload
+// can keep an unchanged bucket's state.
+func (b *Bucket) Same(rate float64, burst int) bool {
+	if burst < 1 {
+		burst = 1
+	}
+	return b.rate == rate && b.burst == float64(burst)
+}
@@ -0,0 +1,118 @@
+package limit
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/example/jsoninputguard/internal/guard"
+)
+
+// ConcurrencyConfig tunes the adaptive concurrency limiter. Zero values take
+// the defaults noted on each field.
+type ConcurrencyConfig struct {
+	// Min and Max bound the limit. Defaults 4 and 256.
+	Min, Max int
+	// Initial limit. Defaults to Max/4.
+	Initial int
+	// Target is the request latency above which the limit backs off.
+	// Defaults to 50ms.
+	Target time.Duration
+	// Backoff multiplies the limit when a request finishes slower than
+	// Target. Shed requests never ran, so they leave the limit alone.
+	// Defaults to 0.9.
+	Backoff float64
+}
+
+// Concurrency is an AIMD concurrency limiter: every request finishing under
+// Target grows the limit by 1/limit (one slot per round trip), a slower one
+// multiplies it by Backoff, at most once per Target so a burst of slow
+// requests does not collapse it to Min. Requests over the limit are shed
+// with 503 before their body is read.
+type Concurrency struct {
+	cfg ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	lastDrop time.Time
+}
+
+// NewConcurrency returns a limiter for cfg.
+func NewConcurrency(cfg ConcurrencyConfig) *Concurrency {
+	if cfg.Max <= 0 {
+		cfg.Max = 256
+	}
+	if cfg.Min <= 0 {
+		cfg.Min = 4
+	}
+	if cfg.Min > cfg.Max {
+		cfg.Min = cfg.Max
+	}
+	if cfg.Initial <= 0 {
+		cfg.Initial = cfg.Max / 4
+	}
+	if cfg.Target <= 0 {
+		cfg.Target = 50 * time.Millisecond
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+	c := &Concurrency{cfg: cfg}
+	c.limit = c.clamp(float64(cfg.Initial))
+	return c
+}
+
+func (c *Concurrency) clamp(l float64) float64 {
+	return math.Max(float64(c.cfg.Min), math.Min(float64(c.cfg.Max), l))
+}
+
+// Acquire takes a slot if the limit allows.
+func (c *Concurrency) Acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight >= int(c.limit) {
+		return false
+	}
+	c.inflight++
+	return true
+}
+
+// Release returns a slot taken at start and adjusts the limit by the
+// request's latency.
+func (c *Concurrency) Release(start, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if now.Sub(start) <= c.cfg.Target {
+		c.limit = c.clamp(c.limit + 1/c.limit)
+		return
+	}
+	if now.Sub(c.lastDrop) >= c.cfg.Target {
+		c.limit = c.clamp(c.limit * c.cfg.Backoff)
+		c.lastDrop = now
+	}
+}
+
+// Limit reports the current limit and the requests in flight.
+func (c *Concurrency) Limit() (limit, inflight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit), c.inflight
+}
+
+var errOverloaded = &guard.Error{Status: http.StatusServiceUnavailable, Code: "overloaded", Message: "server overloaded", RetryAfter: time.Second}
+
+// Middleware sheds requests over the limit with 503 and Retry-After.
+func (c *Concurrency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.Acquire() {
+			guard.WriteError(w, r, errOverloaded)
+			return
+		}
+		start := time.Now()
+		defer func() { c.Release(start, time.Now()) }()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,122 @@
+package limit
+
+import (
+	"container/list"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/jsoninputguard/internal/guard"
+)
+
+// Keyed holds one token bucket per client key, bounded to MaxKeys buckets.
+// When full, the least recently used key is evicted; an evicted client comes
+// back with a full bucket, which errs on the side of admitting it.
+type Keyed struct {
+	rate    float64
+	burst   int
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List // of *keyedBucket, most recent first
+}
+
+type keyedBucket struct {
+	key string
+	b   *Bucket
+}
+
+// NewKeyed returns a limiter allowing rate requests per second per key with
+// bursts of up to burst, tracking at most maxKeys keys.
+func NewKeyed(rate float64, burst, maxKeys int) *Keyed {
+	if maxKeys < 1 {
+		maxKeys = 1
+	}
+	return &Keyed{rate: rate, burst: burst, maxKeys: maxKeys, buckets: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Allow takes a token from key's bucket at now.
+func (k *Keyed) Allow(key string, now time.Time) (bool, time.Duration) {
+	k.mu.Lock()
+	e, ok := k.buckets[key]
+	if ok {
+		k.lru.MoveToFront(e)
+	} else {
+		if k.lru.Len() >= k.maxKeys {
+			old := k.lru.Back()
+			k.lru.Remove(old)
+			delete(k.buckets, old.Value.(*keyedBucket).key)
+		}
+		e = k.lru.PushFront(&keyedBucket{key: key, b: NewBucket(k.rate, k.burst)})
+		k.buckets[key] = e
+	}
+	b := e.Value.(*keyedBucket).b
+	k.mu.Unlock()
+	return b.Allow(now)
+}
+
+// Len reports the number of keys tracked.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lru.Len()
+}
+
+// KeyFunc extracts the client key from a request before its body is read.
+// An empty key is not limited.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys on the connection's remote address. Proxy headers are ignored
+// since clients can forge them.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByAPIKey keys on X-API-Key or the Authorization bearer token.
+func ByAPIKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// RateLimited is the 429 for a client told to retry after wait.
+func RateLimited(field string, wait time.Duration) *guard.Error {
+	return &guard.Error{Status: http.StatusTooManyRequests, Code: "rate_limited", Field: field, Message: "rate limit exceeded", RetryAfter: wait}
+}
+
+// Middleware limits requests by key before the body is read.
+func (k *Keyed) Middleware(key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := key(r); id != "" {
+				if ok, wait := k.Allow(id, guard.Now()); !ok {
+					guard.WriteError(w, r, RateLimited("", wait))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserStage limits by the payload's user_id, decoded, so escaping it
+// differently does not make a new client.
+func (k *Keyed) UserStage() guard.Stage {
+	return guard.StageFunc(func(r *http.Request, _ []byte, scan *guard.PredictScan) error {
+		if ok, wait := k.Allow(scan.User, guard.Now()); !ok {
+			return RateLimited("user_id", wait)
+		}
+		return nil
+	})
+}
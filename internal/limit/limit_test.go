@@ -0,0 +1,93 @@
+package limit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestKeyed(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	k := NewKeyed(1, 2, 2)
+
+	for i := 0; i < 2; i++ {
+		ok, _ := k.Allow("a", now)
+		assert.True(t, ok)
+	}
+	ok, wait := k.Allow("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	ok, _ = k.Allow("b", now)
+	assert.True(t, ok)
+	ok, _ = k.Allow("c", now) // evicts a, the least recently used
+	assert.True(t, ok)
+	assert.Equal(t, 2, k.Len())
+	ok, _ = k.Allow("a", now)
+	assert.True(t, ok, "evicted key starts with a full bucket")
+
+	rr := httptest.NewRecorder()
+	h := NewKeyed(1, 1, 10).Middleware(ByIP)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for i := 0; i < 2; i++ {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+}
+
+func TestUserStage_KeysOnDecodedUserID(t *testing.T) {
+	k := NewKeyed(1, 1, 16)
+	h := guard.WithStages(k.UserStage())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.PredictRequest
+		_ = guard.DecodeValidateJSON(w, r, &req, nil)
+	}))
+	send := func(userID string) int {
+		rr := httptest.NewRecorder()
+		body := `{"user_id":"` + userID + `","session_id":"s","timestamp":1,"features":[1]}`
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body))))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, send("u"))
+	assert.Equal(t, http.StatusTooManyRequests, send(`\u0075`), "same user, escaped")
+	assert.Equal(t, http.StatusOK, send("v"))
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(ConcurrencyConfig{Min: 1, Max: 10, Initial: 2, Target: 10 * time.Millisecond})
+	start := time.Unix(1_700_000_000, 0)
+
+	assert.True(t, c.Acquire())
+	assert.True(t, c.Acquire())
+	assert.False(t, c.Acquire())
+
+	// Fast requests grow the limit additively.
+	c.Release(start, start.Add(time.Millisecond))
+	c.Release(start, start.Add(time.Millisecond))
+	l, inflight := c.Limit()
+	assert.Equal(t, 2, l)
+	assert.Equal(t, 0, inflight)
+	for i := 0; i < 20; i++ {
+		c.Acquire()
+		c.Release(start, start.Add(time.Millisecond))
+	}
+	l, _ = c.Limit()
+	assert.Greater(t, l, 4)
+
+	// Slow requests back off, at most once per target interval.
+	c.Acquire()
+	c.Acquire()
+	c.Release(start, start.Add(time.Second))
+	before, _ := c.Limit()
+	c.Release(start, start.Add(time.Second))
+	after, _ := c.Limit()
+	assert.Equal(t, before, after)
+	assert.Less(t, after, l)
+}
@@ -0,0 +1,109 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// gbtModel is a gradient-boosted tree ensemble. Each tree is a flat node list
+// rooted at index 0; a split sends x[feature] < threshold left, otherwise
+// right, and NaN follows default_left. The score is base_score plus the sum of
+// the reached leaves, passed through a sigmoid for the "logistic" objective.
+//
+//	{"name": "risk", "type": "gbt", "objective": "logistic", "base_score": 0,
+//	 "trees": [{"nodes": [{"feature": 0, "threshold": 0.5, "left": 1, "right": 2},
+//	                      {"leaf": -0.3}, {"leaf": 0.4}]}]}
+type gbtModel struct {
+	Objective string    `json:"objective"`
+	BaseScore float64   `json:"base_score"`
+	Trees     []gbtTree `json:"trees"`
+
+	minFeatures int
+}
+
+type gbtTree struct {
+	Nodes []gbtNode `json:"nodes"`
+}
+
+type gbtNode struct {
+	Feature     int      `json:"feature"`
+	Threshold   float32  `json:"threshold"`
+	Left        int      `json:"left"`
+	Right       int      `json:"right"`
+	DefaultLeft bool     `json:"default_left"`
+	Leaf        *float64 `json:"leaf,omitempty"`
+}
+
+func parseGBT(b []byte) (*gbtModel, error) {
+	m := &gbtModel{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	switch m.Objective {
+	case "", "regression", "logistic":
+	default:
+		return nil, fmt.Errorf("unknown objective %q", m.Objective)
+	}
+	if len(m.Trees) == 0 {
+		return nil, errors.New("trees: empty")
+	}
+	for t, tree := range m.Trees {
+		if len(tree.Nodes) == 0 {
+			return nil, fmt.Errorf("tree %d: no nodes", t)
+		}
+		for n, node := range tree.Nodes {
+			if node.Leaf != nil {
+				continue
+			}
+			// Children must come after their parent, which rules out cycles
+			// and bounds evaluation to len(nodes) steps.
+			if node.Left <= n || node.Right <= n || node.Left >= len(tree.Nodes) || node.Right >= len(tree.Nodes) {
+				return nil, fmt.Errorf("tree %d node %d: bad child index", t, n)
+			}
+			if node.Feature < 0 {
+				return nil, fmt.Errorf("tree %d node %d: bad feature index", t, n)
+			}
+			if node.Feature+1 > m.minFeatures {
+				m.minFeatures = node.Feature + 1
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *gbtModel) Score(ctx context.Context, features []float32, _ map[string]string) (float32, error) {
+	if len(features) < m.minFeatures {
+		return 0, ErrFeatureCount
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	z := m.BaseScore
+	for t := range m.Trees {
+		nodes := m.Trees[t].Nodes
+		i := 0
+		for nodes[i].Leaf == nil {
+			n := &nodes[i]
+			x := features[n.Feature]
+			switch {
+			case x != x:
+				if n.DefaultLeft {
+					i = n.Left
+				} else {
+					i = n.Right
+				}
+			case x < n.Threshold:
+				i = n.Left
+			default:
+				i = n.Right
+			}
+		}
+		z += *nodes[i].Leaf
+	}
+	if m.Objective == "logistic" {
+		z = sigmoid(z)
+	}
+	return float32(z), nil
+}
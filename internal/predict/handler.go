@@ -1,53 +1,337 @@
 package predict
 
 import (
+	"errors"
+	"expvar"
+	"log"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
 
+	"github.com/example/jsoninputguard/internal/abuse"
+	"github.com/example/jsoninputguard/internal/auth"
+	"github.com/example/jsoninputguard/internal/drift"
 	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+	"github.com/example/jsoninputguard/internal/preprocess"
 	"github.com/example/jsoninputguard/internal/types"
 	"github.com/example/jsoninputguard/internal/validate"
 )
 
-// Router returns a chi router with the /predict route.
-func Router() *chi.Mux {
+// Option configures the handler built by Router.
+type Option func(*handler)
+
+// WithPreprocessing applies the active manifest's per-model pipeline to
+// features between validation and scoring.
+func WithPreprocessing(s *preprocess.Store) Option {
+	return func(h *handler) { h.preprocess = s }
+}
+
+// WithModels serves scores from the registry instead of the placeholder sum.
+func WithModels(r *Registry) Option {
+	return func(h *handler) { h.models = r }
+}
+
+// WithShadowRecorder receives shadow comparisons instead of the log.
+func WithShadowRecorder(rec ShadowRecorder) Option {
+	return func(h *handler) { h.shadowRecorder = rec }
+}
+
+// WithDriftMonitor records every feature vector accepted for a known model,
+// as sent and before preprocessing, and serves the statistics on GET /admin/drift when WithAuth
+// is set.
+func WithDriftMonitor(m *drift.Monitor) Option {
+	return func(h *handler) { h.drift = m }
+}
+
+// WithGuardStages runs optional guard stages, such as replay protection, on
+// every /predict payload the guard accepts.
+func WithGuardStages(stages ...guard.Stage) Option {
+	return func(h *handler) { h.stages = append(h.stages, stages...) }
+}
+
+// WithSignatures requires a valid X-Signature on /predict requests.
+func WithSignatures(v *guard.SignatureVerifier) Option {
+	return func(h *handler) { h.signatures = v }
+}
+
+// WithAuth requires an API key on every route and applies the tenant's guard
+// policy and rate limit to /predict. Admin routes need an admin tenant.
+func WithAuth(a *auth.Authenticator) Option {
+	return func(h *handler) { h.auth = a }
+}
+
+// WithRateLimit limits /predict per client key before the body is read. To
+// limit by user_id, pass l.UserStage() to WithGuardStages instead.
+func WithRateLimit(l *limit.Keyed, key limit.KeyFunc) Option {
+	return func(h *handler) { h.rateLimit = l.Middleware(key) }
+}
+
+// WithConcurrencyLimit sheds /predict requests over the adaptive limit.
+func WithConcurrencyLimit(c *limit.Concurrency) Option {
+	return func(h *handler) { h.concurrency = c }
+}
+
+// WithAbuseBlocking temporarily blocks /predict clients, identified by key,
+// that keep sending payloads the guard rejects. With WithAuth, blocks are
+// listed and lifted on /admin/blocks and counted on /admin/metrics.
+func WithAbuseBlocking(t *abuse.Tracker, key limit.KeyFunc) Option {
+	return func(h *handler) { h.abuse, h.abuseKey = t, key }
+}
+
+// WithPII scans /predict metadata for PII and secrets with the actions the
+// policy sets for each route.
+func WithPII(s *guard.PIIScanner) Option {
+	return func(h *handler) { h.pii = s }
+}
+
+// WithInjectionRules rejects /predict payloads whose user_id, session_id or
+// metadata values match the injection rule pack.
+func WithInjectionRules(m *guard.InjectionMatcher) Option {
+	return func(h *handler) { h.injection = m }
+}
+
+// WithCoercion accepts numbers sent as strings in the timestamp and
+// features of the given routes, e.g. "/predict", flagging such responses
+// with X-Guard-Flags: coerced. Other routes stay strict.
+func WithCoercion(routes ...string) Option {
+	return func(h *handler) { h.coerce = append(h.coerce, routes...) }
+}
+
+// WithSchemaVersions reads /predict bodies declaring a past schema version,
+// by guard.VersionHeader or a "version" field, as that version and migrates
+// them to the current one before the guard's checks.
+func WithSchemaVersions(vs *guard.Versions) Option {
+	return func(h *handler) { h.versions = vs }
+}
+
+type handler struct {
+	versions       *guard.Versions
+	coerce         []string
+	injection      *guard.InjectionMatcher
+	pii            *guard.PIIScanner
+	abuse          *abuse.Tracker
+	abuseKey       limit.KeyFunc
+	concurrency    *limit.Concurrency
+	rateLimit      func(http.Handler) http.Handler
+	auth           *auth.Authenticator
+	signatures     *guard.SignatureVerifier
+	stages         []guard.Stage
+	preprocess     *preprocess.Store
+	drift          *drift.Monitor
+	models         *Registry
+	shadowRecorder ShadowRecorder
+	shadowSlots    chan struct{}
+}
+
+func newHandler(opts ...Option) *handler {
+	h := &handler{shadowRecorder: logRecorder{}, shadowSlots: make(chan struct{}, maxShadowInFlight)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// featurePool recycles feature slices across requests; decoding appends into
+// them and preprocessing runs in place.
+var featurePool = &sync.Pool{New: func() any { b := make([]float32, 0, preprocess.MaxDim); return &b }}
+
+// Router returns a chi router with the /predict and /predict/{model} routes,
+// plus admin routes for the configured options and /openapi.json describing
+// them all.
+func Router(opts ...Option) *chi.Mux {
+	h := newHandler(opts...)
+
 	r := chi.NewRouter()
 	// Minimal middleware to keep latency budget tight. Add a soft time budget.
 	r.Use(guard.TimeBudgetMiddleware(950 * time.Millisecond))
 
-	r.Post("/predict", PredictHandler)
+	r.Group(func(r chi.Router) {
+		if h.abuse != nil {
+			r.Use(h.abuse.Middleware(h.abuseKey))
+		}
+		if h.concurrency != nil {
+			r.Use(h.concurrency.Middleware)
+		}
+		if h.rateLimit != nil {
+			r.Use(h.rateLimit)
+		}
+		if h.auth != nil {
+			r.Use(h.auth.Middleware)
+		}
+		if h.signatures != nil {
+			r.Use(h.signatures.Middleware)
+		}
+		if h.versions != nil {
+			r.Use(h.versions.Middleware)
+		}
+		if len(h.stages) > 0 {
+			r.Use(guard.WithStages(h.stages...))
+		}
+		// Installed before the per-route PII checks, so rules see values
+		// before any redaction.
+		if h.injection != nil {
+			r.Use(guard.WithPayloadChecks(h.injection.Check))
+		}
+		for _, route := range []string{"/predict", "/predict/{model}"} {
+			var mw []func(http.Handler) http.Handler
+			if route == "/predict/{model}" {
+				// Constraints on model see the one the path selects.
+				mw = append(mw, guard.WithRouteModel(func(r *http.Request) string { return chi.URLParam(r, "model") }))
+			}
+			if slices.Contains(h.coerce, route) {
+				mw = append(mw, guard.WithCoercion())
+			}
+			if h.pii != nil {
+				mw = append(mw, h.pii.Middleware(route))
+			}
+			r.With(mw...).Post(route, h.predict)
+		}
+	})
+	h.mountAdmin(r)
+	// Public, so clients can always fetch the contract.
+	r.Get("/openapi.json", h.serveOpenAPI(r))
 	return r
 }
 
+// mountAdmin registers the /admin routes of the configured options. They
+// can lift blocks and expose traffic statistics, so they are only mounted
+// behind an admin API key.
+func (h *handler) mountAdmin(r chi.Router) {
+	var metrics []string
+	if h.abuse != nil {
+		metrics = append(metrics, "abuse")
+	}
+	if len(h.coerce) > 0 {
+		metrics = append(metrics, "coercion")
+	}
+	if h.drift == nil && len(metrics) == 0 {
+		return
+	}
+	if h.auth == nil {
+		log.Printf("admin routes disabled: they need API keys with an admin tenant")
+		return
+	}
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth.AdminMiddleware)
+		if h.drift != nil {
+			r.Method(http.MethodGet, "/admin/drift", h.drift.Handler())
+		}
+		if h.abuse != nil {
+			r.Method(http.MethodGet, "/admin/blocks", h.abuse.Handler())
+			r.Method(http.MethodDelete, "/admin/blocks", h.abuse.Handler())
+		}
+		if len(metrics) > 0 {
+			r.Method(http.MethodGet, "/admin/metrics", metricsHandler(metrics...))
+		}
+	})
+}
+
+// metricsHandler serves the named expvar variables as one JSON object. Unlike
+// expvar.Handler it leaves out cmdline and memstats.
+func metricsHandler(names ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := make(map[string]json.RawMessage, len(names))
+		for _, name := range names {
+			if v := expvar.Get(name); v != nil {
+				vars[name] = json.RawMessage(v.String())
+			}
+		}
+		writeJSON(w, http.StatusOK, vars)
+	})
+}
+
+var defaultHandler = newHandler()
+
+// PredictHandler serves /predict with the placeholder scorer and no preprocessing.
 func PredictHandler(w http.ResponseWriter, r *http.Request) {
-	var req types.PredictRequest
+	defaultHandler.predict(w, r)
+}
+
+func (h *handler) predict(w http.ResponseWriter, r *http.Request) {
+	fp := featurePool.Get().(*[]float32)
+	defer featurePool.Put(fp)
+
+	req := types.PredictRequest{Features: (*fp)[:0]}
 	if err := guard.DecodeValidateJSON(w, r, &req, func(p *types.PredictRequest) error {
 		return validate.V().Struct(p)
 	}); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
 		return
 	}
+	*fp = req.Features[:0]
 
-	// Simulate a very cheap scoring function to isolate guard overhead.
-	score := fastScore(req.Features)
+	name := modelName(r, &req)
+	rt, err := h.resolve(name, req.UserID)
+	if err != nil {
+		guard.WriteError(w, r, &guard.Error{Status: http.StatusNotFound, Code: "unknown_model", Field: "model", Message: "unknown model: " + name})
+		return
+	}
+	if h.drift != nil {
+		h.drift.Observe(req.Features)
+	}
+
+	var shadowRaw []float32
+	if rt.shadowScorer != nil {
+		shadowRaw = h.shadowCopy(req.Features)
+		// Release the reserved slot unless the shadow goroutine took it over.
+		defer func() {
+			if shadowRaw != nil {
+				<-h.shadowSlots
+			}
+		}()
+	}
+
+	features, err := h.preprocess.Load().For(rt.model).Apply(req.Features)
+	if err != nil {
+		guard.WriteError(w, r, &guard.Error{Status: http.StatusBadRequest, Code: "feature_count", Field: "features", Message: err.Error()})
+		return
+	}
+	*fp = features[:0]
+
+	score, err := rt.scorer.Score(r.Context(), features, req.Metadata)
+	if err != nil {
+		ge := &guard.Error{Status: http.StatusInternalServerError, Code: "scoring_failed", Message: err.Error()}
+		if errors.Is(err, ErrFeatureCount) {
+			ge = &guard.Error{Status: http.StatusBadRequest, Code: "feature_count", Field: "features", Message: err.Error()}
+		}
+		guard.WriteError(w, r, ge)
+		return
+	}
+	if shadowRaw != nil {
+		h.scoreShadow(r.Context(), name, req.UserID, rt, score, shadowRaw, req.Metadata)
+		shadowRaw = nil
+	}
+	if h.models != nil {
+		w.Header().Set("X-Model", rt.model)
+	}
 	resp := types.PredictResponse{Score: score}
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func fastScore(features []float32) float32 {
-	var s float32
-	// Sum first 16 items at most to keep deterministic and cheap
-	limit := 16
-	if len(features) < limit {
-		limit = len(features)
+// modelName picks the model from the route parameter, then the body's model
+// field, then DefaultModel.
+func modelName(r *http.Request, req *types.PredictRequest) string {
+	if m := chi.URLParam(r, "model"); m != "" {
+		return m
+	}
+	if req.Model != "" {
+		return req.Model
 	}
-	for i := 0; i < limit; i++ {
-		s += features[i]
+	return DefaultModel
+}
+
+// resolve maps the requested name to a model version, applying splits.
+// Without a registry every request is served by the placeholder sum so
+// benchmarks isolate guard overhead.
+func (h *handler) resolve(name, userID string) (route, error) {
+	if h.models == nil {
+		return route{model: name, scorer: sumScorer{}}, nil
 	}
-	return s
+	return h.models.resolve(name, userID)
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -0,0 +1,51 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// linearModel scores bias + w·x, optionally through a sigmoid.
+//
+//	{"name": "churn-v1", "type": "logistic", "bias": -1.2, "weights": [0.4, -0.1]}
+type linearModel struct {
+	Bias     float64   `json:"bias"`
+	Weights  []float64 `json:"weights"`
+	logistic bool
+}
+
+func parseLinear(b []byte, logistic bool) (*linearModel, error) {
+	m := &linearModel{logistic: logistic}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if len(m.Weights) == 0 {
+		return nil, errors.New("weights: empty")
+	}
+	for _, w := range m.Weights {
+		if math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, errors.New("weights: not finite")
+		}
+	}
+	return m, nil
+}
+
+func (m *linearModel) Score(_ context.Context, features []float32, _ map[string]string) (float32, error) {
+	if len(features) != len(m.Weights) {
+		return 0, ErrFeatureCount
+	}
+	z := m.Bias
+	for i, w := range m.Weights {
+		z += w * float64(features[i])
+	}
+	if m.logistic {
+		z = sigmoid(z)
+	}
+	return float32(z), nil
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
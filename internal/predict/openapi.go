@@ -0,0 +1,185 @@
+package predict
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/jsoninputguard/internal/auth"
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/schema"
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+// openAPIDoc caches the document for the rules it was built from, so it is
+// rebuilt only after a rules reload.
+type openAPIDoc struct {
+	rules *guard.Rules
+	body  []byte
+}
+
+// serveOpenAPI serves the OpenAPI 3.1 document for the routes registered on
+// mux, with the /predict request schema read from the struct tags on
+// types.PredictRequest and tightened by the active guard rules.
+func (h *handler) serveOpenAPI(mux chi.Routes) http.HandlerFunc {
+	var cache atomic.Pointer[openAPIDoc]
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules := guard.ActiveRules()
+		doc := cache.Load()
+		if doc == nil || doc.rules != rules {
+			body, err := json.Marshal(h.openAPI(mux, rules))
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+				return
+			}
+			doc = &openAPIDoc{rules: rules, body: body}
+			cache.Store(doc)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc.body)
+	}
+}
+
+func (h *handler) openAPI(mux chi.Routes, rules *guard.Rules) map[string]any {
+	paths := map[string]map[string]any{}
+	_ = chi.Walk(mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/*") // chi.Walk reports mounted groups this way
+		if paths[route] == nil {
+			paths[route] = map[string]any{}
+		}
+		paths[route][strings.ToLower(method)] = h.operation(method, route, rules)
+		return nil
+	})
+
+	errSchema := schema.MustFromStruct(guard.Error{})
+	errSchema.Properties["code"].Required = true
+	errSchema.Properties["error"].Required = true
+	components := map[string]any{
+		"schemas": map[string]any{
+			"PredictRequest":  schema.WithRules(schema.MustFromStruct(types.PredictRequest{}), rules).JSONSchema(),
+			"PredictResponse": schema.MustFromStruct(types.PredictResponse{}).JSONSchema(),
+			"Error":           errSchema.JSONSchema(),
+		},
+	}
+	doc := map[string]any{
+		"openapi":    "3.1.0",
+		"info":       map[string]any{"title": "jsoninputguard", "version": "1"},
+		"paths":      paths,
+		"components": components,
+	}
+	if h.auth != nil {
+		components["securitySchemes"] = map[string]any{
+			"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": auth.KeyHeader},
+		}
+		doc["security"] = []any{map[string]any{"apiKey": []string{}}}
+	}
+	return doc
+}
+
+// Summaries of the routes Router can register.
+var routeSummaries = map[string]string{
+	"POST /predict":         "Score a feature vector with the default or body-selected model",
+	"POST /predict/{model}": "Score a feature vector with the named model",
+	"GET /admin/drift":      "Feature drift statistics",
+	"GET /admin/blocks":     "Clients blocked for repeated invalid requests",
+	"DELETE /admin/blocks":  "Lift a client's block",
+	"GET /admin/metrics":    "Guard counters",
+	"GET /openapi.json":     "This document",
+}
+
+func (h *handler) operation(method, route string, rules *guard.Rules) map[string]any {
+	op := map[string]any{"summary": routeSummaries[method+" "+route]}
+	responses := map[string]any{}
+	var params []any
+	header := func(name, desc string, required bool) {
+		params = append(params, map[string]any{"name": name, "in": "header", "required": required,
+			"description": desc, "schema": map[string]any{"type": "string"}})
+	}
+
+	switch {
+	case strings.HasPrefix(route, "/predict"):
+		if strings.Contains(route, "{model}") {
+			params = append(params, map[string]any{"name": "model", "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"}})
+		}
+		header("Accept-Language", "Language of error messages: en, fr, es or de.", false)
+		if h.signatures != nil {
+			header(guard.SignatureHeader, "HMAC-SHA256 of the timestamp and body: t=<unix>,k=<key id>,v1=<hex>.", true)
+		}
+		if h.versions != nil {
+			header(guard.VersionHeader, "Schema version the body was written for; "+h.versions.Current()+" if absent.", false)
+		}
+		op["requestBody"] = map[string]any{
+			"required":    true,
+			"description": "At most " + strconv.Itoa(rules.MaxPayloadSize) + " bytes.",
+			"content":     jsonContent("PredictRequest"),
+		}
+		ok := map[string]any{"description": "Score", "content": jsonContent("PredictResponse"),
+			"headers": map[string]any{guard.FlagsHeader: headerSpec("Non-fatal guard findings: outlier, pii, coerced.")}}
+		responses["200"] = ok
+		responses["400"] = errorResponse("Rejected by the guard", false)
+		responses["413"] = errorResponse("Payload too large", false)
+		responses["500"] = errorResponse("Scoring failed", false)
+		if h.models != nil {
+			responses["404"] = errorResponse("Unknown model", false)
+			ok["headers"].(map[string]any)["X-Model"] = headerSpec("Model version that served the request.")
+		}
+		if h.auth != nil || h.signatures != nil {
+			responses["401"] = errorResponse("Missing or invalid credentials", false)
+		}
+		if h.abuse != nil {
+			responses["403"] = errorResponse("Client blocked", true)
+		}
+		if len(h.stages) > 0 {
+			responses["409"] = errorResponse("Rejected by a guard stage, such as replay protection", false)
+		}
+		if h.rateLimit != nil || h.auth != nil {
+			responses["429"] = errorResponse("Rate limited", true)
+		}
+		if h.concurrency != nil {
+			responses["503"] = errorResponse("Overloaded", true)
+		}
+	case strings.HasPrefix(route, "/admin/"):
+		responses["200"] = map[string]any{"description": "OK", "content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}}
+		if method == http.MethodDelete {
+			params = append(params, map[string]any{"name": "client", "in": "query", "required": true,
+				"schema": map[string]any{"type": "string"}})
+			responses["404"] = errorResponse("Client not blocked", false)
+		}
+		responses["401"] = errorResponse("Missing or invalid API key", false)
+		responses["403"] = errorResponse("Admin access required", false)
+	default:
+		responses["200"] = map[string]any{"description": "OK"}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	op["responses"] = responses
+	if route == "/openapi.json" && h.auth != nil {
+		op["security"] = []any{} // served without a key
+	}
+	return op
+}
+
+func jsonContent(ref string) map[string]any {
+	return map[string]any{"application/json": map[string]any{
+		"schema": map[string]any{"$ref": "#/components/schemas/" + ref}}}
+}
+
+func headerSpec(desc string) map[string]any {
+	return map[string]any{"description": desc, "schema": map[string]any{"type": "string"}}
+}
+
+func errorResponse(desc string, retryAfter bool) map[string]any {
+	resp := map[string]any{"description": desc, "content": jsonContent("Error"),
+		"headers": map[string]any{"Content-Language": headerSpec("Language of the error message.")}}
+	if retryAfter {
+		resp["headers"].(map[string]any)["Retry-After"] = headerSpec("Seconds to wait before retrying.")
+	}
+	return resp
+}
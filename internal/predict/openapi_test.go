@@ -0,0 +1,75 @@
+package predict
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/limit"
+)
+
+func TestOpenAPI(t *testing.T) {
+	router := Router(WithRateLimit(limit.NewKeyed(10, 10, 100), limit.ByIP), WithSchemaVersions(guard.NewVersions("2")))
+	fetch := func() map[string]any {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var doc map[string]any
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+		return doc
+	}
+	get := func(v any, path ...string) any {
+		for _, p := range path {
+			v = v.(map[string]any)[p]
+		}
+		return v
+	}
+
+	doc := fetch()
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	assert.ElementsMatch(t, []string{"/predict", "/predict/{model}", "/openapi.json"}, keys(doc["paths"]))
+	predict := get(doc, "paths", "/predict", "post")
+	assert.ElementsMatch(t, []string{"200", "400", "413", "429", "500"}, keys(get(predict, "responses")))
+	assert.NotNil(t, get(predict, "responses", "429", "headers", "Retry-After"))
+	assert.Len(t, get(predict, "parameters"), 2) // Accept-Language, X-Schema-Version
+	assert.Len(t, get(doc, "paths", "/predict/{model}", "post", "parameters"), 3)
+
+	req := get(doc, "components", "schemas", "PredictRequest")
+	assert.Equal(t, 64.0, get(req, "properties", "user_id", "maxLength"))
+	assert.ElementsMatch(t, []any{"user_id", "session_id", "timestamp", "features"}, get(req, "required"))
+	assert.ElementsMatch(t, []any{"code", "error"}, get(doc, "components", "schemas", "Error", "required"))
+
+	// The document follows the active rules.
+	prev := guard.ActiveRules()
+	defer guard.SetRules(prev)
+	rules, err := guard.ParseRules([]byte(`{"max_user_id_len": 32, "max_features": 8, "unknown_fields": {"*": "reject"},
+		"charset": {"session_id": {"type": "ulid"}}, "fields": {"timestamp": {"null": "missing", "default": "now"}},
+		"timestamp": {"unit": "ms", "max_age": "5m"}, "structure": {"max_depth": 4}, "allowed_metadata_keys": ["region"],
+		"constraints": [{"id": "dim", "expr": "len(features) == 8"}]}`))
+	assert.NoError(t, err)
+	guard.SetRules(rules)
+
+	req = get(fetch(), "components", "schemas", "PredictRequest")
+	assert.Equal(t, 32.0, get(req, "properties", "user_id", "maxLength"))
+	assert.Equal(t, 8.0, get(req, "properties", "features", "maxItems"))
+	assert.Equal(t, false, get(req, "additionalProperties"))
+	assert.NotEmpty(t, get(req, "properties", "session_id", "pattern"))
+	assert.ElementsMatch(t, []any{"user_id", "session_id", "features"}, get(req, "required"))
+	assert.Equal(t, []any{"region"}, get(req, "properties", "metadata", "propertyNames", "enum"))
+	assert.Equal(t, 4.0, get(req, "x-structure", "max_depth"))
+	assert.Equal(t, "5m0s", get(req, "x-timestamp", "max_age"))
+	assert.Equal(t, "missing", get(req, "x-fields", "timestamp", "null"))
+	assert.Equal(t, "dim", get(req, "x-constraints").([]any)[0].(map[string]any)["id"])
+}
+
+func keys(m any) []string {
+	var out []string
+	for k := range m.(map[string]any) {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/example/jsoninputguard/internal/preprocess"
+)
+
+// DefaultModel is served when a request names no model.
+const DefaultModel = preprocess.DefaultModel
+
+// ErrFeatureCount is returned by scorers when the vector does not match the
+// model's input width.
+var ErrFeatureCount = errors.New("feature count does not match model")
+
+// Scorer turns a validated, preprocessed feature vector into a score.
+// Implementations must be safe for concurrent use and must not retain features.
+type Scorer interface {
+	Score(ctx context.Context, features []float32, metadata map[string]string) (float32, error)
+}
+
+// Registry maps model names to scorers, with optional splits layered on top
+// (see SetSplits). Lookups are lock-free; Register swaps
+// in a new map so requests already holding a scorer keep using it.
+type Registry struct {
+	mu     sync.Mutex // serializes writers
+	models atomic.Pointer[map[string]Scorer]
+	splits atomic.Pointer[map[string]*Split]
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	r := &Registry{}
+	m := map[string]Scorer{}
+	r.models.Store(&m)
+	return r
+}
+
+// Register adds or replaces the scorer for name.
+func (r *Registry) Register(name string, s Scorer) {
+	_ = r.Replace("", name, s) // drops nothing, so no split can break
+}
+
+// Replace atomically registers s under name and drops oldName, for a model
+// file that was renamed on reload. oldName may be empty or equal to name.
+// It fails, leaving the registry as it was, if a split still routes to
+// oldName.
+func (r *Registry) Replace(oldName, name string, s Scorer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := *r.models.Load()
+	m := make(map[string]Scorer, len(old)+1)
+	for k, v := range old {
+		if k != oldName {
+			m[k] = v
+		}
+	}
+	m[name] = s
+	if sp := r.splits.Load(); sp != nil {
+		if err := checkSplits(*sp, m); err != nil {
+			return err
+		}
+	}
+	r.models.Store(&m)
+	return nil
+}
+
+// Lookup returns the scorer registered under name.
+func (r *Registry) Lookup(name string) (Scorer, bool) {
+	s, ok := (*r.models.Load())[name]
+	return s, ok
+}
+
+// Names lists registered models.
+func (r *Registry) Names() []string {
+	m := *r.models.Load()
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	return names
+}
+
+// modelFile is the common header of every model file; the remaining fields
+// depend on Type.
+type modelFile struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// LoadModel reads a model file and returns its name and scorer. Supported
+// types are "linear", "logistic" and "gbt".
+func LoadModel(path string) (string, Scorer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return ParseModel(b)
+}
+
+// ParseModel decodes and validates a model document.
+func ParseModel(b []byte) (string, Scorer, error) {
+	var hdr modelFile
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return "", nil, fmt.Errorf("model: %w", err)
+	}
+	if hdr.Name == "" {
+		return "", nil, errors.New("model: missing name")
+	}
+	var (
+		s   Scorer
+		err error
+	)
+	switch hdr.Type {
+	case "linear", "logistic":
+		s, err = parseLinear(b, hdr.Type == "logistic")
+	case "gbt":
+		s, err = parseGBT(b)
+	default:
+		err = fmt.Errorf("unknown type %q", hdr.Type)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("model %q: %w", hdr.Name, err)
+	}
+	return hdr.Name, s, nil
+}
+
+// LoadRegistry loads every model file into a new registry.
+func LoadRegistry(paths ...string) (*Registry, error) {
+	r := NewRegistry()
+	for _, p := range paths {
+		name, s, err := LoadModel(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if _, dup := r.Lookup(name); dup {
+			return nil, fmt.Errorf("%s: duplicate model %q", p, name)
+		}
+		r.Register(name, s)
+	}
+	return r, nil
+}
+
+// sumScorer is the placeholder used when no registry is configured. It sums
+// the first 16 features to keep scoring deterministic and cheap so benchmarks
+// isolate guard overhead.
+type sumScorer struct{}
+
+func (sumScorer) Score(_ context.Context, features []float32, _ map[string]string) (float32, error) {
+	var s float32
+	limit := 16
+	if len(features) < limit {
+		limit = len(features)
+	}
+	for i := 0; i < limit; i++ {
+		s += features[i]
+	}
+	return s, nil
+}
@@ -0,0 +1,149 @@
+package predict
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/auth"
+	"github.com/example/jsoninputguard/internal/drift"
+	"github.com/example/jsoninputguard/internal/guard"
+)
+
+func TestParseModel_Logistic(t *testing.T) {
+	name, s, err := ParseModel([]byte(`{"name": "lr", "type": "logistic", "bias": 0, "weights": [1, -1]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "lr", name)
+
+	score, err := s.Score(context.Background(), []float32{2, 2}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.5), score)
+
+	_, err = s.Score(context.Background(), []float32{1}, nil)
+	assert.ErrorIs(t, err, ErrFeatureCount)
+}
+
+func TestParseModel_GBT(t *testing.T) {
+	_, s, err := ParseModel([]byte(`{"name": "gbt", "type": "gbt", "base_score": 1, "trees": [
+		{"nodes": [{"feature": 0, "threshold": 0.5, "left": 1, "right": 2}, {"leaf": -1}, {"leaf": 2}]},
+		{"nodes": [{"feature": 1, "threshold": 0, "left": 1, "right": 2, "default_left": true}, {"leaf": 10}, {"leaf": 20}]}
+	]}`))
+	assert.NoError(t, err)
+
+	score, err := s.Score(context.Background(), []float32{1, -1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1+2+10), score)
+
+	_, _, err = ParseModel([]byte(`{"name": "bad", "type": "gbt", "trees": [{"nodes": [{"feature": 0, "left": 0, "right": 0}]}]}`))
+	assert.Error(t, err)
+}
+
+func TestRouter_UnknownModel(t *testing.T) {
+	reg := NewRegistry()
+	mon := drift.New(drift.Config{})
+	h := Router(WithModels(reg), WithDriftMonitor(mon))
+	body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1]}`)
+	r := httptest.NewRequest("POST", "/predict/missing", bytes.NewReader(body))
+	r.Header.Set("Accept-Language", "fr")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "fr", rr.Header().Get("Content-Language"))
+	assert.JSONEq(t, `{"code": "unknown_model", "field": "model", "error": "modèle inconnu"}`, rr.Body.String())
+	assert.Empty(t, mon.Snapshot().Features, "rejected requests are not drift traffic")
+}
+
+func TestRouter_ConstraintsSeeRouteModel(t *testing.T) {
+	rules, err := guard.ParseRules([]byte(`{"constraints": [{"id": "v2-width", "expr": "if model == 'v2' then len(features) == 2"}]}`))
+	assert.NoError(t, err)
+	prev := guard.ActiveRules()
+	defer guard.SetRules(prev)
+	guard.SetRules(rules)
+
+	h := Router()
+	post := func(path, model string) int {
+		body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"model":"` + model + `"}`)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, post("/predict", "v2"))
+	assert.Equal(t, http.StatusBadRequest, post("/predict/v2", "v1"))
+	assert.Equal(t, http.StatusOK, post("/predict/v1", "v2"))
+}
+
+type chanRecorder chan ShadowResult
+
+func (c chanRecorder) Record(res ShadowResult) { c <- res }
+
+func TestSplit_StickyAndShadowed(t *testing.T) {
+	reg := NewRegistry()
+	for _, doc := range []string{
+		`{"name": "v1", "type": "linear", "weights": [1]}`,
+		`{"name": "v2", "type": "linear", "weights": [2]}`,
+		`{"name": "v3", "type": "linear", "weights": [3]}`,
+	} {
+		name, s, err := ParseModel([]byte(doc))
+		assert.NoError(t, err)
+		reg.Register(name, s)
+	}
+	splits, err := ParseSplits([]byte(`{"splits": {"m": {"variants": [
+		{"model": "v1", "weight": 1}, {"model": "v2", "weight": 1}], "shadow": "v3"}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, reg.SetSplits(splits))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		rt, err := reg.resolve("m", user)
+		assert.NoError(t, err)
+		again, _ := reg.resolve("m", user)
+		assert.Equal(t, rt.model, again.model, "sticky per user")
+		counts[rt.model]++
+	}
+	assert.InDelta(t, 500, counts["v1"], 80)
+	assert.InDelta(t, 500, counts["v2"], 80)
+
+	rec := make(chanRecorder, 1)
+	h := Router(WithModels(reg), WithShadowRecorder(rec))
+	body := []byte(`{"user_id":"u","session_id":"s","timestamp":1,"features":[1],"model":"m"}`)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/predict", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	res := <-rec
+	assert.Equal(t, "v3", res.ShadowModel)
+	assert.Equal(t, float32(3), res.ShadowScore)
+	assert.Equal(t, rr.Header().Get("X-Model"), res.Model)
+}
+
+func TestRouter_AdminNeedsAuth(t *testing.T) {
+	get := func(router http.Handler, path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			r.Header.Set(auth.KeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+		return rr
+	}
+	mon := drift.New(drift.Config{})
+	assert.Equal(t, http.StatusNotFound, get(Router(WithDriftMonitor(mon), WithCoercion("/predict")), "/admin/drift", "").Code)
+
+	keys, err := auth.ParseKeys([]byte(fmt.Sprintf(`{"tenants": [{"id": "ops", "admin": true, "key_hashes": [%q]},
+		{"id": "app", "key_hashes": [%q]}]}`, auth.HashKey("ops-key"), auth.HashKey("app-key"))))
+	assert.NoError(t, err)
+	router := Router(WithAuth(auth.New(keys)), WithDriftMonitor(mon), WithCoercion("/predict"))
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/admin/drift", "").Code)
+	assert.Equal(t, http.StatusForbidden, get(router, "/admin/metrics", "app-key").Code)
+	assert.Equal(t, http.StatusOK, get(router, "/admin/drift", "ops-key").Code)
+	rr := get(router, "/admin/metrics", "ops-key")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"coercion"`)
+	assert.NotContains(t, rr.Body.String(), "cmdline")
+	assert.NotContains(t, rr.Body.String(), "memstats")
+}
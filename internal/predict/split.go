@@ -0,0 +1,221 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// Split routes a logical model name to weighted versions. Assignment is
+// sticky per user_id and uses weighted rendezvous hashing, so changing one
+// weight only moves users into or out of that version.
+//
+//	{"splits": {"churn": {"variants": [{"model": "churn-v1", "weight": 90},
+//	                                   {"model": "churn-v2", "weight": 10}],
+//	                      "shadow": "churn-v3"}}}
+type Split struct {
+	Variants []Variant `json:"variants"`
+	// Shadow is scored asynchronously on every request; its score is recorded
+	// but never returned.
+	Shadow string `json:"shadow,omitempty"`
+}
+
+// Variant is one model version in a split.
+type Variant struct {
+	Model  string  `json:"model"`
+	Weight float64 `json:"weight"`
+}
+
+type splitsFile struct {
+	Splits map[string]*Split `json:"splits"`
+}
+
+// LoadSplits reads and validates a splits file.
+func LoadSplits(path string) (map[string]*Split, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSplits(b)
+}
+
+// ParseSplits decodes and validates a splits document.
+func ParseSplits(b []byte) (map[string]*Split, error) {
+	var f splitsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("splits: %w", err)
+	}
+	for name, s := range f.Splits {
+		if s == nil || len(s.Variants) == 0 {
+			return nil, fmt.Errorf("splits: %q: no variants", name)
+		}
+		for _, v := range s.Variants {
+			if v.Model == "" || !(v.Weight > 0) || math.IsInf(v.Weight, 0) {
+				return nil, fmt.Errorf("splits: %q: variant needs a model and a weight > 0", name)
+			}
+		}
+	}
+	return f.Splits, nil
+}
+
+// pick returns the variant for key by weighted rendezvous hashing.
+func (s *Split) pick(key string) string {
+	best, bestScore := "", math.Inf(-1)
+	for _, v := range s.Variants {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(v.Model))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		// Map the hash into (0,1) and weight it: -w/ln(u) is maximal for the
+		// chosen variant with probability proportional to w.
+		u := (float64(mix64(h.Sum64())>>11) + 0.5) / (1 << 53)
+		score := -v.Weight / math.Log(u)
+		if score > bestScore {
+			best, bestScore = v.Model, score
+		}
+	}
+	return best
+}
+
+// mix64 is the splitmix64 finalizer; FNV alone leaves the high bits poorly
+// mixed for keys that differ only in their last bytes.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// route is the resolved scoring target for one request.
+type route struct {
+	model        string
+	scorer       Scorer
+	shadow       string
+	shadowScorer Scorer
+}
+
+// errUnknownModel is returned by resolve when name is neither a split nor a model.
+var errUnknownModel = errors.New("unknown model")
+
+// SetSplits atomically replaces the registry's splits. Every variant and
+// shadow must name a registered model.
+func (r *Registry) SetSplits(splits map[string]*Split) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := checkSplits(splits, *r.models.Load()); err != nil {
+		return err
+	}
+	r.splits.Store(&splits)
+	return nil
+}
+
+// checkSplits reports the first variant or shadow of splits not in models.
+func checkSplits(splits map[string]*Split, models map[string]Scorer) error {
+	for name, s := range splits {
+		for _, v := range s.Variants {
+			if _, ok := models[v.Model]; !ok {
+				return fmt.Errorf("split %q: unknown model %q", name, v.Model)
+			}
+		}
+		if s.Shadow != "" {
+			if _, ok := models[s.Shadow]; !ok {
+				return fmt.Errorf("split %q: unknown shadow model %q", name, s.Shadow)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve maps a requested name to a model, applying splits first.
+func (r *Registry) resolve(name, userID string) (route, error) {
+	var rt route
+	model := name
+	if sp := r.splits.Load(); sp != nil {
+		if s, ok := (*sp)[name]; ok {
+			model = s.pick(userID)
+			if s.Shadow != "" && s.Shadow != model {
+				rt.shadow = s.Shadow
+				rt.shadowScorer, _ = r.Lookup(s.Shadow)
+			}
+		}
+	}
+	sc, ok := r.Lookup(model)
+	if !ok {
+		return rt, errUnknownModel
+	}
+	rt.model, rt.scorer = model, sc
+	return rt, nil
+}
+
+// ShadowResult pairs the served score with the shadow model's score.
+type ShadowResult struct {
+	Split       string  `json:"split"`
+	UserID      string  `json:"user_id"`
+	Model       string  `json:"model"`
+	Score       float32 `json:"score"`
+	ShadowModel string  `json:"shadow_model"`
+	ShadowScore float32 `json:"shadow_score"`
+	ShadowError string  `json:"shadow_error,omitempty"`
+}
+
+// ShadowRecorder receives shadow comparisons. It is called from a background
+// goroutine and must be safe for concurrent use.
+type ShadowRecorder interface {
+	Record(ShadowResult)
+}
+
+// logRecorder writes each comparison as a JSON log line.
+type logRecorder struct{}
+
+func (logRecorder) Record(res ShadowResult) {
+	b, _ := json.Marshal(res)
+	log.Printf("shadow %s", b)
+}
+
+// shadowTimeout bounds a shadow evaluation once the request has returned.
+const shadowTimeout = time.Second
+
+// maxShadowInFlight caps concurrent shadow evaluations; beyond it shadow
+// scoring is skipped rather than competing with served traffic.
+const maxShadowInFlight = 64
+
+// scoreShadow runs the shadow model's own pipeline and scorer on raw, a
+// private copy of the decoded features, in the background.
+func (h *handler) scoreShadow(ctx context.Context, split string, userID string, rt route, score float32, raw []float32, metadata map[string]string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	pipeline := h.preprocess.Load().For(rt.shadow)
+	go func() {
+		defer func() { <-h.shadowSlots }()
+		defer cancel()
+		res := ShadowResult{Split: split, UserID: userID, Model: rt.model, Score: score, ShadowModel: rt.shadow}
+		f, err := pipeline.Apply(raw)
+		if err == nil {
+			res.ShadowScore, err = rt.shadowScorer.Score(ctx, f, metadata)
+		}
+		if err != nil {
+			res.ShadowError = err.Error()
+		}
+		h.shadowRecorder.Record(res)
+	}()
+}
+
+// shadowCopy reserves a shadow slot and copies features before preprocessing
+// mutates them. It returns nil when shadow scoring is saturated. The copy is
+// sized to the vector, not the pooled buffer; padding to the shadow pipeline's
+// Dim grows it as needed.
+func (h *handler) shadowCopy(features []float32) []float32 {
+	select {
+	case h.shadowSlots <- struct{}{}:
+	default:
+		return nil
+	}
+	return append(make([]float32, 0, len(features)), features...)
+}
@@ -0,0 +1,254 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+)
+
+// MaxDim bounds the vector length a pipeline may pad to. It matches the
+// features limit enforced by the guard.
+const MaxDim = 16384
+
+// DefaultModel is the pipeline used when a request names no model.
+const DefaultModel = "default"
+
+// Manifest is the JSON document describing per-model preprocessing:
+//
+//	{"pipelines": {"default": {"dim": 4, "steps": [{"op": "impute", "value": 0}]}}}
+type Manifest struct {
+	Pipelines map[string]*Pipeline `json:"pipelines"`
+}
+
+// Pipeline is an ordered list of steps applied in place to a feature vector.
+type Pipeline struct {
+	// Dim is the expected vector length. Shorter vectors are padded with
+	// missing entries; longer vectors are rejected. Zero disables the check.
+	Dim   int    `json:"dim,omitempty"`
+	Steps []Step `json:"steps"`
+}
+
+// Step is a single transform. Indices restricts the step to the listed
+// positions; when empty the step applies to every position.
+type Step struct {
+	Op       string    `json:"op"`
+	Indices  []int     `json:"indices,omitempty"`
+	Min      *float32  `json:"min,omitempty"`      // clip
+	Max      *float32  `json:"max,omitempty"`      // clip
+	Mean     []float32 `json:"mean,omitempty"`     // zscore, impute with strategy "mean"
+	Std      []float32 `json:"std,omitempty"`      // zscore
+	Offset   float32   `json:"offset,omitempty"`   // log
+	Value    float32   `json:"value,omitempty"`    // impute with strategy "constant"
+	Strategy string    `json:"strategy,omitempty"` // impute: "constant" (default) or "mean"
+}
+
+// Load reads and validates a manifest file.
+func Load(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse decodes and validates a manifest.
+func Parse(b []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("preprocess: %w", err)
+	}
+	for name, p := range m.Pipelines {
+		if p == nil {
+			return nil, fmt.Errorf("preprocess: pipeline %q: empty", name)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("preprocess: pipeline %q: %w", name, err)
+		}
+	}
+	return &m, nil
+}
+
+// passthrough only fills missing entries with zero, matching how encoding/json
+// decodes nulls into []float32.
+var passthrough = &Pipeline{}
+
+// For returns the pipeline for model, falling back to the "default" pipeline
+// and then to a passthrough. It is safe to call on a nil Manifest.
+func (m *Manifest) For(model string) *Pipeline {
+	if m == nil {
+		return passthrough
+	}
+	if p, ok := m.Pipelines[model]; ok {
+		return p
+	}
+	if p, ok := m.Pipelines[DefaultModel]; ok {
+		return p
+	}
+	return passthrough
+}
+
+// Store holds the active manifest so it can be swapped while requests keep
+// using the one they loaded.
+type Store struct {
+	m atomic.Pointer[Manifest]
+}
+
+// NewStore returns a store holding m.
+func NewStore(m *Manifest) *Store {
+	s := &Store{}
+	s.m.Store(m)
+	return s
+}
+
+// Load returns the active manifest. It is safe to call on a nil Store.
+func (s *Store) Load() *Manifest {
+	if s == nil {
+		return nil
+	}
+	return s.m.Load()
+}
+
+// Swap replaces the active manifest.
+func (s *Store) Swap(m *Manifest) { s.m.Store(m) }
+
+func (p *Pipeline) validate() error {
+	if p.Dim < 0 || p.Dim > MaxDim {
+		return fmt.Errorf("dim %d out of bounds", p.Dim)
+	}
+	for i := range p.Steps {
+		if err := p.Steps[i].validate(p.Dim); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i, p.Steps[i].Op, err)
+		}
+	}
+	return nil
+}
+
+func (s *Step) validate(dim int) error {
+	for _, idx := range s.Indices {
+		if idx < 0 || (dim > 0 && idx >= dim) || idx >= MaxDim {
+			return fmt.Errorf("index %d out of bounds", idx)
+		}
+	}
+	switch s.Op {
+	case "clip":
+		if s.Min == nil && s.Max == nil {
+			return fmt.Errorf("needs min or max")
+		}
+		if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+			return fmt.Errorf("min > max")
+		}
+	case "zscore":
+		if len(s.Mean) == 0 || len(s.Mean) != len(s.Std) {
+			return fmt.Errorf("mean and std must be non-empty and of equal length")
+		}
+		if len(s.Indices) > 0 && len(s.Indices) != len(s.Mean) {
+			return fmt.Errorf("mean/std length must match indices")
+		}
+		for i, sd := range s.Std {
+			if !(sd > 0) {
+				return fmt.Errorf("std[%d] must be > 0", i)
+			}
+		}
+	case "log":
+	case "impute":
+		switch s.Strategy {
+		case "", "constant":
+		case "mean":
+			if len(s.Mean) == 0 {
+				return fmt.Errorf("strategy mean needs mean")
+			}
+			if len(s.Indices) > 0 && len(s.Indices) != len(s.Mean) {
+				return fmt.Errorf("mean length must match indices")
+			}
+		default:
+			return fmt.Errorf("unknown strategy %q", s.Strategy)
+		}
+	default:
+		return fmt.Errorf("unknown op")
+	}
+	return nil
+}
+
+// Apply runs the pipeline in place. Missing entries are NaN: the guard decodes
+// JSON nulls as NaN, and vectors shorter than Dim are padded with NaN within
+// the slice's capacity. Any NaN left after the last step is set to zero so
+// scorers never see one. The returned slice shares the input's backing array
+// unless padding needed more capacity.
+func (p *Pipeline) Apply(features []float32) ([]float32, error) {
+	if p.Dim > 0 {
+		if len(features) > p.Dim {
+			return features, fmt.Errorf("preprocess: got %d features, want at most %d", len(features), p.Dim)
+		}
+		for len(features) < p.Dim {
+			features = append(features, float32(math.NaN()))
+		}
+	}
+	for i := range p.Steps {
+		p.Steps[i].apply(features)
+	}
+	for i, x := range features {
+		if x != x {
+			features[i] = 0
+		}
+	}
+	return features, nil
+}
+
+// apply runs the step over every selected position. Position k of the step
+// (the k-th index, or the absolute index when Indices is empty) selects the
+// k-th entry of Mean/Std.
+func (s *Step) apply(f []float32) {
+	n := len(f)
+	if len(s.Indices) > 0 {
+		n = len(s.Indices)
+	}
+	for k := 0; k < n; k++ {
+		i := k
+		if len(s.Indices) > 0 {
+			i = s.Indices[k]
+			if i >= len(f) {
+				continue
+			}
+		}
+		x := f[i]
+		switch s.Op {
+		case "clip":
+			if x != x {
+				continue
+			}
+			if s.Min != nil && x < *s.Min {
+				x = *s.Min
+			}
+			if s.Max != nil && x > *s.Max {
+				x = *s.Max
+			}
+		case "zscore":
+			if k >= len(s.Mean) || x != x {
+				continue
+			}
+			x = (x - s.Mean[k]) / s.Std[k]
+		case "log":
+			// Non-positive inputs become missing so a later impute step decides.
+			if v := x + s.Offset; v > 0 {
+				x = float32(math.Log(float64(v)))
+			} else {
+				x = float32(math.NaN())
+			}
+		case "impute":
+			if x == x {
+				continue
+			}
+			if s.Strategy == "mean" {
+				if k >= len(s.Mean) {
+					continue
+				}
+				x = s.Mean[k]
+			} else {
+				x = s.Value
+			}
+		}
+		f[i] = x
+	}
+}
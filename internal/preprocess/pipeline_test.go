@@ -0,0 +1,51 @@
+package preprocess
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineApply(t *testing.T) {
+	m, err := Parse([]byte(`{"pipelines": {"default": {"dim": 4, "steps": [
+		{"op": "impute", "strategy": "mean", "mean": [1, 2, 3, 4]},
+		{"op": "clip", "indices": [0], "max": 5},
+		{"op": "zscore", "indices": [1], "mean": [2], "std": [2]},
+		{"op": "log", "indices": [2], "offset": 1}
+	]}}}`))
+	assert.NoError(t, err)
+
+	nan := float32(math.NaN())
+	f := make([]float32, 0, 4)
+	f = append(f, 10, 6, nan)
+	out, err := m.For("unknown").Apply(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{5, 2, float32(math.Log(4)), 4}, out)
+	assert.Equal(t, &f[0], &out[0], "applied in place")
+}
+
+func TestPipelineApply_TooLong(t *testing.T) {
+	m, err := Parse([]byte(`{"pipelines": {"m": {"dim": 1, "steps": []}}}`))
+	assert.NoError(t, err)
+	_, err = m.For("m").Apply([]float32{1, 2})
+	assert.Error(t, err)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"pipelines": {"m": {"steps": [{"op": "square"}]}}}`,
+		`{"pipelines": {"m": {"steps": [{"op": "zscore", "mean": [0], "std": [0]}]}}}`,
+		`{"pipelines": {"m": {"dim": 2, "steps": [{"op": "clip", "indices": [2], "min": 0}]}}}`,
+	} {
+		_, err := Parse([]byte(doc))
+		assert.Error(t, err, doc)
+	}
+}
+
+func TestNilManifestZeroFills(t *testing.T) {
+	var m *Manifest
+	out, err := m.For("any").Apply([]float32{float32(math.NaN()), 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0, 1}, out)
+}
@@ -0,0 +1,126 @@
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Loader validates file contents and, only if they are valid, swaps them in.
+// Returning an error leaves the previously loaded version active.
+type Loader func(b []byte) error
+
+// Watcher polls configuration files and hands changed contents to their
+// loaders. Changes are detected by SHA-256 so touching a file is a no-op.
+type Watcher struct {
+	// Interval between polls. Zero disables polling; Reload still works.
+	Interval time.Duration
+	// Logf reports reloads and failures. Defaults to log.Printf.
+	Logf func(format string, args ...any)
+
+	mu    sync.Mutex
+	files []*watched
+}
+
+type watched struct {
+	path   string
+	load   Loader
+	sum    string // checksum of the active version
+	failed string // checksum of the last rejected version, to log it once
+}
+
+// Add loads path once and starts tracking it. An invalid initial file is an
+// error since there is no previous version to fall back to.
+func (w *Watcher) Add(path string, load Loader) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := load(b); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	f := &watched{path: path, load: load, sum: checksum(b)}
+	w.logf("loaded %s sha256=%s", path, f.sum)
+
+	w.mu.Lock()
+	w.files = append(w.files, f)
+	w.mu.Unlock()
+	return nil
+}
+
+// Run polls until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		return
+	}
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.check(false)
+		}
+	}
+}
+
+// Reload re-reads every file and reloads it even if unchanged, as on SIGHUP.
+func (w *Watcher) Reload() { w.check(true) }
+
+func (w *Watcher) check(force bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range w.files {
+		b, err := os.ReadFile(f.path)
+		if err != nil {
+			w.logf("reload %s: %v; keeping sha256=%s", f.path, err, f.sum)
+			continue
+		}
+		sum := checksum(b)
+		if !force && (sum == f.sum || sum == f.failed) {
+			continue
+		}
+		if err := f.load(b); err != nil {
+			f.failed = sum
+			w.logf("reload %s sha256=%s rejected: %v; keeping sha256=%s", f.path, sum, err, f.sum)
+			continue
+		}
+		w.logf("reloaded %s sha256=%s (was %s)", f.path, sum, f.sum)
+		f.sum, f.failed = sum, ""
+	}
+}
+
+func (w *Watcher) logf(format string, args ...any) {
+	if w.Logf != nil {
+		w.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func checksum(b []byte) string {
+	s := sha256.Sum256(b)
+	return hex.EncodeToString(s[:])
+}
+
+// Once loads files a single time without watching them, for environments
+// such as Lambda where the process does not outlive a deploy.
+type Once struct{}
+
+// Add reads path and applies it with load.
+func (Once) Add(path string, load Loader) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := load(b); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
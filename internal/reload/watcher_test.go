@@ -0,0 +1,48 @@
+package reload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_BadFileKeepsPrevious(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	assert.NoError(t, os.WriteFile(path, []byte("v1"), 0o600))
+
+	var active string
+	w := &Watcher{Logf: t.Logf}
+	err := w.Add(path, func(b []byte) error {
+		if string(b) == "bad" {
+			return errors.New("invalid")
+		}
+		active = string(b)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", active)
+
+	assert.NoError(t, os.WriteFile(path, []byte("bad"), 0o600))
+	w.check(false)
+	assert.Equal(t, "v1", active)
+
+	assert.NoError(t, os.WriteFile(path, []byte("v2"), 0o600))
+	w.check(false)
+	assert.Equal(t, "v2", active)
+}
+
+func TestWatcher_ReloadForcesUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.json")
+	assert.NoError(t, os.WriteFile(path, []byte("v1"), 0o600))
+
+	loads := 0
+	w := &Watcher{Logf: t.Logf}
+	assert.NoError(t, w.Add(path, func([]byte) error { loads++; return nil }))
+	w.check(false)
+	assert.Equal(t, 1, loads)
+	w.Reload()
+	assert.Equal(t, 2, loads)
+}
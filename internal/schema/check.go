@@ -0,0 +1,236 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/example/jsoninputguard/internal/guard"
+	"github.com/example/jsoninputguard/internal/validate"
+)
+
+// Violation is the first rule a payload breaks.
+type Violation struct {
+	Path string
+	Rule string // required, additional, type, min, max, enum, pattern or the format
+}
+
+func (v *Violation) Error() string { return v.Path + ": " + v.Rule }
+
+// Check reports the first rule of s that payload breaks, reading the rules as
+// the validator does: required rejects null and the zero values "", 0 and
+// false (an empty array or object is present), and omitempty lets a zero
+// value skip the other rules. Null array elements are accepted as missing
+// entries, as the guard reads them. Members s does not describe are only
+// checked for when s is Closed.
+func (s *Schema) Check(payload []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return &Violation{Path: "$", Rule: "type"}
+	}
+	if s.Closed {
+		for name := range obj {
+			if _, ok := s.Properties[name]; !ok {
+				return &Violation{Path: name, Rule: "additional"}
+			}
+		}
+	}
+	for _, name := range s.Names() {
+		f := s.Properties[name]
+		v, ok := obj[name]
+		if !ok || isZero(v) {
+			if f.Required {
+				return &Violation{Path: name, Rule: "required"}
+			}
+			if v == nil {
+				continue
+			}
+		}
+		if err := f.check(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Field) check(path string, v any) error {
+	var size float64
+	switch f.Type {
+	case TypeString:
+		s, ok := v.(string)
+		if !ok {
+			return &Violation{Path: path, Rule: "type"}
+		}
+		size = float64(utf8.RuneCountInString(s))
+	case TypeInteger, TypeNumber:
+		n, ok := v.(json.Number)
+		if !ok || f.Type == TypeInteger && strings.ContainsAny(string(n), ".eE") {
+			return &Violation{Path: path, Rule: "type"}
+		}
+		size, _ = n.Float64()
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return &Violation{Path: path, Rule: "type"}
+		}
+	case TypeArray:
+		a, ok := v.([]any)
+		if !ok {
+			return &Violation{Path: path, Rule: "type"}
+		}
+		size = float64(len(a))
+		for _, e := range a {
+			if e == nil || f.Items == nil {
+				continue
+			}
+			if err := f.Items.check(path+"[]", e); err != nil {
+				return err
+			}
+		}
+	case TypeObject:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return &Violation{Path: path, Rule: "type"}
+		}
+		size = float64(len(m))
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys) // so the reported violation is stable
+		for _, k := range keys {
+			if f.Keys != nil {
+				if err := f.Keys.check(path+"{key}", k); err != nil {
+					return err
+				}
+			}
+			if m[k] == nil || f.Items == nil {
+				continue
+			}
+			if err := f.Items.check(path+"{}", m[k]); err != nil {
+				return err
+			}
+		}
+	}
+	if f.OmitEmpty && isZero(v) {
+		return nil
+	}
+	if f.Min != nil && size < *f.Min {
+		return &Violation{Path: path, Rule: "min"}
+	}
+	if f.Max != nil && size > *f.Max {
+		return &Violation{Path: path, Rule: "max"}
+	}
+	if len(f.Enum) > 0 {
+		if s, ok := v.(string); !ok || !slices.Contains(f.Enum, s) {
+			if n, ok := v.(json.Number); !ok || !slices.Contains(f.Enum, string(n)) {
+				return &Violation{Path: path, Rule: "enum"}
+			}
+		}
+	}
+	if f.Pattern != "" {
+		s, _ := v.(string)
+		if re, err := compilePattern(f.Pattern); err != nil || !re.MatchString(s) {
+			return &Violation{Path: path, Rule: "pattern"}
+		}
+	}
+	if !checkFormat(f.Format, v) {
+		return &Violation{Path: path, Rule: f.Format}
+	}
+	return nil
+}
+
+var patternCache sync.Map // string -> *regexp.Regexp
+
+// compilePattern compiles each Field.Pattern once, however many values it
+// is checked against.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// isZero reports whether v is null or the zero value of a scalar, which is
+// what required and omitempty test for.
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		n, err := v.Float64()
+		return err == nil && n == 0
+	}
+	return false
+}
+
+// checkFormat applies the domain tags a payload can break; other formats,
+// such as finite, always hold for JSON values.
+func checkFormat(format string, v any) bool {
+	switch format {
+	case "uuid4_lower":
+		s, _ := v.(string)
+		return validate.IsUUID4Lower(s)
+	case "metadata_key":
+		s, _ := v.(string)
+		return validate.IsMetadataKey(s)
+	case "epoch_ms":
+		n, _ := v.(json.Number)
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return err == nil && validate.IsEpochMS(i)
+	}
+	return true
+}
+
+// Report is the outcome of replaying a corpus against two versions.
+type Report struct {
+	Payloads     int            // non-empty lines read
+	RejectedFrom int            // by the old version
+	RejectedTo   int            // by the new version
+	Newly        int            // accepted by the old version, rejected by the new
+	Reasons      map[string]int // the Violations behind Newly
+}
+
+// Replay checks every payload of a JSONL corpus, one per line, against both
+// versions, counting those the new one would newly reject.
+func Replay(from, to *Schema, corpus io.Reader) (*Report, error) {
+	rep := &Report{Reasons: map[string]int{}}
+	sc := bufio.NewScanner(corpus)
+	sc.Buffer(make([]byte, 0, guard.MaxPayloadSize), 16*guard.MaxPayloadSize)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rep.Payloads++
+		before, after := from.Check(line), to.Check(line)
+		if before != nil {
+			rep.RejectedFrom++
+		}
+		if after != nil {
+			rep.RejectedTo++
+			if before == nil {
+				rep.Newly++
+				rep.Reasons[after.Error()]++
+			}
+		}
+	}
+	return rep, sc.Err()
+}
@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Change is one difference between two versions of a contract. A breaking
+// change can reject a payload the old version accepted.
+type Change struct {
+	Path     string // e.g. "features", or "metadata{}" and "metadata{key}" for its values and keys
+	Kind     string // see the Change kinds below
+	Breaking bool
+	Old, New string
+}
+
+// Change kinds.
+const (
+	FieldAdded     = "field_added"
+	FieldRemoved   = "field_removed"
+	RequiredAdded  = "required_added"
+	RequiredGone   = "required_removed"
+	TypeChanged    = "type_changed"
+	MinChanged     = "min_changed"
+	MaxChanged     = "max_changed"
+	EnumChanged    = "enum_changed"
+	FormatChanged  = "format_changed"
+	PatternChanged = "pattern_changed"
+	ClosedChanged  = "closed_changed"
+	OmitChanged    = "omitempty_changed"
+)
+
+func (c Change) String() string {
+	class := "compatible"
+	if c.Breaking {
+		class = "BREAKING"
+	}
+	return fmt.Sprintf("%-10s %s: %s %s -> %s", class, c.Path, c.Kind, orNone(c.Old), orNone(c.New))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// Diff classifies every change from one version to the next, in path order.
+// Removing a field is compatible when the new schema is open, since payloads
+// that still send it are read as before; a closed one rejects them.
+func Diff(from, to *Schema) []Change {
+	var changes []Change
+	if from.Closed != to.Closed {
+		changes = append(changes, Change{Path: "$", Kind: ClosedChanged, Breaking: to.Closed, Old: closed(from), New: closed(to)})
+	}
+	names := from.Names()
+	for _, name := range to.Names() {
+		if _, ok := from.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		o, n := from.Properties[name], to.Properties[name]
+		switch {
+		case n == nil:
+			changes = append(changes, Change{Path: name, Kind: FieldRemoved, Breaking: to.Closed, Old: o.Type})
+		case o == nil:
+			changes = append(changes, Change{Path: name, Kind: FieldAdded, Breaking: n.Required, New: describe(n)})
+		default:
+			if o.Required != n.Required {
+				kind := RequiredGone
+				if n.Required {
+					kind = RequiredAdded
+				}
+				changes = append(changes, Change{Path: name, Kind: kind, Breaking: n.Required})
+			}
+			changes = diffField(changes, name, o, n)
+		}
+	}
+	return changes
+}
+
+func diffField(changes []Change, path string, o, n *Field) []Change {
+	if o.Type != n.Type {
+		// Every integer is a number, but not the other way round.
+		widened := o.Type == TypeInteger && n.Type == TypeNumber
+		return append(changes, Change{Path: path, Kind: TypeChanged, Breaking: !widened, Old: o.Type, New: n.Type})
+	}
+	if !sameBound(o.Min, n.Min) {
+		changes = append(changes, Change{Path: path, Kind: MinChanged, Breaking: tighter(n.Min, o.Min, 1), Old: bound(o.Min), New: bound(n.Min)})
+	}
+	if !sameBound(o.Max, n.Max) {
+		changes = append(changes, Change{Path: path, Kind: MaxChanged, Breaking: tighter(n.Max, o.Max, -1), Old: bound(o.Max), New: bound(n.Max)})
+	}
+	if !slices.Equal(sorted(o.Enum), sorted(n.Enum)) {
+		// A new enum, or one missing a value the old one had, rejects more.
+		breaking := len(n.Enum) > 0 && (len(o.Enum) == 0 || slices.ContainsFunc(o.Enum, func(v string) bool { return !slices.Contains(n.Enum, v) }))
+		changes = append(changes, Change{Path: path, Kind: EnumChanged, Breaking: breaking, Old: strings.Join(o.Enum, " "), New: strings.Join(n.Enum, " ")})
+	}
+	if o.Format != n.Format {
+		changes = append(changes, Change{Path: path, Kind: FormatChanged, Breaking: n.Format != "", Old: o.Format, New: n.Format})
+	}
+	if o.OmitEmpty != n.OmitEmpty {
+		// Without omitempty, zero values face the other rules.
+		changes = append(changes, Change{Path: path, Kind: OmitChanged, Breaking: o.OmitEmpty, Old: omit(o), New: omit(n)})
+	}
+	if o.Pattern != n.Pattern {
+		// Patterns are not compared: any new one may reject more.
+		changes = append(changes, Change{Path: path, Kind: PatternChanged, Breaking: n.Pattern != "", Old: o.Pattern, New: n.Pattern})
+	}
+	if o.Items != nil && n.Items != nil {
+		suffix := "[]"
+		if o.Type == TypeObject {
+			suffix = "{}"
+		}
+		changes = diffField(changes, path+suffix, o.Items, n.Items)
+	}
+	if o.Type == TypeObject && (o.Keys != nil || n.Keys != nil) {
+		changes = diffField(changes, path+"{key}", keys(o), keys(n))
+	}
+	return changes
+}
+
+// keys returns the rules on f's keys; without any, every string is a key.
+func keys(f *Field) *Field {
+	if f.Keys == nil {
+		return &Field{Type: TypeString}
+	}
+	return f.Keys
+}
+
+func omit(f *Field) string {
+	if f.OmitEmpty {
+		return "omitempty"
+	}
+	return ""
+}
+
+// tighter reports whether bound n accepts less than o, where sign is 1 for
+// a minimum and -1 for a maximum.
+func tighter(n, o *float64, sign float64) bool {
+	if n == nil {
+		return false
+	}
+	return o == nil || (*n-*o)*sign > 0
+}
+
+func sameBound(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func bound(b *float64) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*b, 'g', -1, 64)
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+func closed(s *Schema) string {
+	if s.Closed {
+		return "closed"
+	}
+	return "open"
+}
+
+func describe(f *Field) string {
+	if f.Required {
+		return "required " + f.Type
+	}
+	return f.Type
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"slices"
+
+	"github.com/example/jsoninputguard/internal/guard"
+)
+
+// Patterns for the guard's identifier charsets, in the syntax both Go and
+// JSON Schema (ECMA 262) read the same way.
+var charsetPatterns = map[string]string{
+	"ascii_id": `^[A-Za-z0-9._:-]*$`,
+	"ulid":     `^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`,
+}
+
+// WithRules returns a copy of s, the schema of types.PredictRequest,
+// tightened by what the guard enforces on top of the tags under rules: the
+// identifier lengths and charsets, the feature count, the allowed metadata
+// keys, the unknown-field policy and fields that a default makes optional.
+// Rules JSON Schema cannot express are listed as extensions: x-structure,
+// x-timestamp, x-fields (null policies and defaults) and x-constraints.
+func WithRules(s *Schema, rules *guard.Rules) *Schema {
+	s = s.Clone()
+	p := s.Properties
+	atMost(p["user_id"], rules.MaxUserIDLen)
+	atMost(p["session_id"], rules.MaxSessionIDLen)
+	atMost(p["features"], rules.MaxFeatures)
+	if f := p["features"]; f != nil && (f.Min == nil || *f.Min < float64(rules.MinFeatures)) {
+		n := float64(rules.MinFeatures)
+		f.Min = &n
+	}
+	withCharset(p["user_id"], rules.Charset.UserID)
+	withCharset(p["session_id"], rules.Charset.SessionID)
+
+	policy, ok := rules.UnknownFields["PredictRequest"]
+	if !ok {
+		policy = rules.UnknownFields["*"]
+	}
+	s.Closed = policy == guard.UnknownReject
+
+	for name, fp := range rules.Fields {
+		if f := p[name]; f != nil && len(fp.Default) > 0 {
+			f.Required = false
+		}
+	}
+	if f := p["metadata"]; f != nil && len(rules.AllowedMetadataKeys) > 0 {
+		if f.Keys == nil {
+			f.Keys = &Field{Type: TypeString}
+		}
+		f.Keys.Enum = slices.Clone(rules.AllowedMetadataKeys)
+	}
+
+	if s.Extensions == nil {
+		s.Extensions = map[string]any{}
+	}
+	s.Extensions["x-structure"] = rules.Structure
+	if rules.Timestamp != (guard.TimestampRules{}) {
+		s.Extensions["x-timestamp"] = rules.Timestamp
+	}
+	if len(rules.Fields) > 0 {
+		s.Extensions["x-fields"] = rules.Fields
+	}
+	if len(rules.Constraints) > 0 {
+		s.Extensions["x-constraints"] = rules.Constraints
+	}
+	return s
+}
+
+func atMost(f *Field, max int) {
+	if f == nil || max <= 0 {
+		return
+	}
+	if n := float64(max); f.Max == nil || *f.Max > n {
+		f.Max = &n
+	}
+}
+
+func withCharset(f *Field, c guard.IDCharset) {
+	if f == nil {
+		return
+	}
+	switch c.Type {
+	case "uuid":
+		if f.Format == "" { // keep a stricter domain tag
+			f.Format = "uuid"
+		}
+	case "regex":
+		f.Pattern = "^(?:" + c.Pattern + ")$"
+	default:
+		if pattern, ok := charsetPatterns[c.Type]; ok {
+			f.Pattern = pattern
+		}
+	}
+}
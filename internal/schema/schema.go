@@ -0,0 +1,246 @@
+// Package schema models the JSON contract of a request type, read from its
+// struct tags or a JSON Schema document, so two versions of it can be
+// compared and replayed against past payloads.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Schema is the contract of a JSON object.
+type Schema struct {
+	Properties map[string]*Field
+	// Closed rejects members not in Properties.
+	Closed bool
+	// Extensions are x- keywords rendered as they are, for rules JSON Schema
+	// cannot express. Check, Diff and Parse ignore them.
+	Extensions map[string]any
+}
+
+// Field is one member's contract. Min and Max bound the length of strings
+// (in runes), arrays and objects, and the value of numbers.
+type Field struct {
+	Type     string // string, integer, number, boolean, array or object
+	Required bool   // present and not the zero value, as the validator reads it
+	// OmitEmpty skips the other rules for the zero value: "", 0 or false.
+	OmitEmpty bool
+	Min, Max  *float64
+	Enum      []string
+	Format    string // a domain tag such as uuid4_lower, see package validate
+	Pattern   string // a regular expression strings must match
+	Items     *Field // array elements, or object values
+	Keys      *Field // object keys, if they have rules
+}
+
+// Types a Field can have, as JSON Schema names them.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// Bound keywords by type, as JSON Schema spells them.
+var boundKeywords = map[string][2]string{
+	TypeString:  {"minLength", "maxLength"},
+	TypeInteger: {"minimum", "maximum"},
+	TypeNumber:  {"minimum", "maximum"},
+	TypeArray:   {"minItems", "maxItems"},
+	TypeObject:  {"minProperties", "maxProperties"},
+}
+
+// Names returns the property names in order.
+func (s *Schema) Names() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MarshalJSON renders s as a JSON Schema (2020-12) object schema.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.JSONSchema())
+}
+
+// JSONSchema returns s as a JSON Schema object schema.
+func (s *Schema) JSONSchema() map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, name := range s.Names() {
+		f := s.Properties[name]
+		props[name] = f.JSONSchema()
+		if f.Required {
+			required = append(required, name)
+		}
+	}
+	doc := map[string]any{"type": TypeObject, "properties": props}
+	if len(required) > 0 {
+		doc["required"] = required
+	}
+	if s.Closed {
+		doc["additionalProperties"] = false
+	}
+	for k, v := range s.Extensions {
+		doc[k] = v
+	}
+	return doc
+}
+
+// Clone returns a deep copy of s.
+func (s *Schema) Clone() *Schema {
+	c := &Schema{Properties: make(map[string]*Field, len(s.Properties)), Closed: s.Closed, Extensions: maps.Clone(s.Extensions)}
+	for name, f := range s.Properties {
+		c.Properties[name] = f.clone()
+	}
+	return c
+}
+
+func (f *Field) clone() *Field {
+	c := *f
+	c.Enum = slices.Clone(f.Enum)
+	if f.Items != nil {
+		c.Items = f.Items.clone()
+	}
+	if f.Keys != nil {
+		c.Keys = f.Keys.clone()
+	}
+	return &c
+}
+
+// JSONSchema returns f as a JSON Schema. Required is its parent's to render;
+// OmitEmpty, which JSON Schema has no keyword for, is x-omitempty.
+func (f *Field) JSONSchema() map[string]any {
+	doc := map[string]any{"type": f.Type}
+	if f.OmitEmpty {
+		doc["x-omitempty"] = true
+	}
+	kw := boundKeywords[f.Type]
+	if f.Min != nil {
+		doc[kw[0]] = *f.Min
+	}
+	if f.Max != nil {
+		doc[kw[1]] = *f.Max
+	}
+	if len(f.Enum) > 0 {
+		doc["enum"] = f.Enum
+	}
+	if f.Format != "" {
+		doc["format"] = f.Format
+	}
+	if f.Pattern != "" {
+		doc["pattern"] = f.Pattern
+	}
+	if f.Items != nil {
+		key := "items"
+		if f.Type == TypeObject {
+			key = "additionalProperties"
+		}
+		doc[key] = f.Items.JSONSchema()
+	}
+	if f.Keys != nil {
+		doc["propertyNames"] = f.Keys.JSONSchema()
+	}
+	return doc
+}
+
+// jsonSchema is the subset of JSON Schema Parse reads.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Enum                 []any                  `json:"enum"`
+	Format               string                 `json:"format"`
+	Pattern              string                 `json:"pattern"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"` // a schema, or false
+	PropertyNames        *jsonSchema            `json:"propertyNames"`
+	OmitEmpty            bool                   `json:"x-omitempty"`
+
+	MinLength     *float64 `json:"minLength"`
+	MaxLength     *float64 `json:"maxLength"`
+	Minimum       *float64 `json:"minimum"`
+	Maximum       *float64 `json:"maximum"`
+	MinItems      *float64 `json:"minItems"`
+	MaxItems      *float64 `json:"maxItems"`
+	MinProperties *float64 `json:"minProperties"`
+	MaxProperties *float64 `json:"maxProperties"`
+}
+
+// Parse reads an object schema written by MarshalJSON, or by hand in the
+// same subset of JSON Schema: type, properties, required, enum, format,
+// pattern, items, additionalProperties, propertyNames, x-omitempty and the
+// length, value, item and property bounds. Other keywords are ignored.
+func Parse(data []byte) (*Schema, error) {
+	var doc jsonSchema
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	if doc.Type != TypeObject {
+		return nil, fmt.Errorf("schema: top level must be an object schema")
+	}
+	s := &Schema{Properties: map[string]*Field{}, Closed: string(doc.AdditionalProperties) == "false"}
+	for name, p := range doc.Properties {
+		f, err := p.field()
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s: %w", name, err)
+		}
+		f.Required = slices.Contains(doc.Required, name)
+		s.Properties[name] = f
+	}
+	return s, nil
+}
+
+func (d *jsonSchema) field() (*Field, error) {
+	f := &Field{Type: d.Type, OmitEmpty: d.OmitEmpty, Format: d.Format, Pattern: d.Pattern}
+	if d.Pattern != "" {
+		if _, err := compilePattern(d.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	switch d.Type {
+	case TypeString:
+		f.Min, f.Max = d.MinLength, d.MaxLength
+	case TypeInteger, TypeNumber:
+		f.Min, f.Max = d.Minimum, d.Maximum
+	case TypeArray:
+		f.Min, f.Max = d.MinItems, d.MaxItems
+	case TypeObject:
+		f.Min, f.Max = d.MinProperties, d.MaxProperties
+	case TypeBoolean:
+	default:
+		return nil, fmt.Errorf("unknown type %q", d.Type)
+	}
+	for _, v := range d.Enum {
+		f.Enum = append(f.Enum, fmt.Sprint(v))
+	}
+	items := d.Items
+	if d.Type == TypeObject && len(d.AdditionalProperties) > 0 && d.AdditionalProperties[0] == '{' {
+		if err := json.Unmarshal(d.AdditionalProperties, &items); err != nil {
+			return nil, err
+		}
+	}
+	if items != nil {
+		var err error
+		if f.Items, err = items.field(); err != nil {
+			return nil, err
+		}
+	}
+	if d.Type == TypeObject && d.PropertyNames != nil {
+		if d.PropertyNames.Type == "" {
+			d.PropertyNames.Type = TypeString // implied: keys are strings
+		}
+		var err error
+		if f.Keys, err = d.PropertyNames.field(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
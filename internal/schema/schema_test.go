@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type predictV1 struct {
+	UserID   string            `json:"user_id" validate:"required,max=64"`
+	Score    float64           `json:"score" validate:"unit_interval"`
+	Count    int               `json:"count"`
+	Mode     string            `json:"mode" validate:"omitempty,oneof=fast slow"`
+	Features []float32         `json:"features" validate:"required,feature_vector=16"`
+	Metadata map[string]string `json:"metadata" validate:"max=8,dive,keys,metadata_key,endkeys,max=32"`
+	Legacy   string            `json:"legacy"`
+}
+
+type predictV2 struct {
+	UserID   string            `json:"user_id" validate:"required,max=36,uuid4_lower"`
+	Score    float64           `json:"score" validate:"unit_interval"`
+	Count    float64           `json:"count"`
+	Mode     string            `json:"mode" validate:"omitempty,oneof=fast"`
+	Features []float32         `json:"features" validate:"required,feature_vector=32"`
+	Metadata map[string]string `json:"metadata" validate:"max=8,dive,keys,metadata_key,endkeys,max=16"`
+	Model    string            `json:"model" validate:"required"`
+}
+
+func TestDiff(t *testing.T) {
+	from, err := FromStruct(reflect.TypeOf(predictV1{}))
+	assert.NoError(t, err)
+	to := MustFromStruct(predictV2{})
+
+	var got []string
+	for _, c := range Diff(from, to) {
+		got = append(got, c.String())
+	}
+	assert.Equal(t, []string{
+		"compatible count: type_changed integer -> number",
+		"compatible features: max_changed 16 -> 32",
+		"compatible legacy: field_removed string -> none",
+		"BREAKING   metadata{}: max_changed 32 -> 16",
+		"BREAKING   mode: enum_changed fast slow -> fast",
+		"BREAKING   model: field_added none -> required string",
+		"BREAKING   user_id: max_changed 64 -> 36",
+		"BREAKING   user_id: format_changed none -> uuid4_lower",
+	}, got)
+
+	// A JSON Schema round trip keeps the contract.
+	doc, err := to.MarshalJSON()
+	assert.NoError(t, err)
+	parsed, err := Parse(doc)
+	assert.NoError(t, err)
+	assert.Empty(t, Diff(to, parsed))
+	assert.Equal(t, to, parsed)
+
+	// Once unknown fields are rejected, dropping one breaks its senders.
+	closedTo := to.Clone()
+	closedTo.Closed = true
+	assert.Contains(t, Diff(from, closedTo), Change{Path: "legacy", Kind: FieldRemoved, Breaking: true, Old: "string"})
+
+	_, err = Parse([]byte(`{"type": "object", "properties": {"a": {"type": "date"}}}`))
+	assert.EqualError(t, err, `schema: a: unknown type "date"`)
+	_, err = Parse([]byte(`{"type": "object", "properties": {"a": {"type": "string", "pattern": "("}}}`))
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	from, to := MustFromStruct(predictV1{}), MustFromStruct(predictV2{})
+	corpus := strings.Join([]string{
+		`{"user_id":"9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b","features":[1,null],"model":"m"}`,
+		`{"user_id":"u1","features":[1],"model":"m"}`,
+		`{"user_id":"9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b","features":[1],"mode":"slow","model":"m"}`,
+		`{"user_id":"9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b","features":[1]}`,
+		`{"features":[1],"model":"m"}`,
+		``,
+		`{"user_id":"9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b","features":[1],"score":2,"model":"m"}`,
+	}, "\n")
+	rep, err := Replay(from, to, strings.NewReader(corpus))
+	assert.NoError(t, err)
+	assert.Equal(t, &Report{Payloads: 6, RejectedFrom: 2, RejectedTo: 5, Newly: 3, Reasons: map[string]int{
+		"user_id: uuid4_lower": 1,
+		"mode: enum":           1,
+		"model: required":      1,
+	}}, rep)
+
+	closedTo := to.Clone()
+	closedTo.Closed = true
+	rep, err = Replay(from, closedTo, strings.NewReader(`{"user_id":"9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b","features":[1],"legacy":"x","model":"m"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Report{Payloads: 1, RejectedTo: 1, Newly: 1, Reasons: map[string]int{"legacy: additional": 1}}, rep)
+}
+
+func TestKeysAndZeroValues(t *testing.T) {
+	type before struct {
+		At   int64             `json:"at" validate:"required"`
+		Mode string            `json:"mode" validate:"omitempty,oneof=fast slow"`
+		Tags []string          `json:"tags" validate:"dive,oneof=a b"`
+		Meta map[string]string `json:"meta" validate:"dive,keys,max=64,endkeys,max=8"`
+	}
+	type after struct {
+		At   int64             `json:"at" validate:"required"`
+		Mode string            `json:"mode" validate:"oneof=fast slow"`
+		Tags []string          `json:"tags" validate:"dive,oneof=a b"`
+		Meta map[string]string `json:"meta" validate:"dive,keys,metadata_key,endkeys,max=8"`
+	}
+	from, to := MustFromStruct(before{}), MustFromStruct(after{})
+	assert.Equal(t, []string{"a", "b"}, from.Properties["tags"].Items.Enum)
+
+	var got []string
+	for _, c := range Diff(from, to) {
+		got = append(got, c.String())
+	}
+	assert.Equal(t, []string{
+		"compatible meta{key}: max_changed 64 -> none",
+		"BREAKING   meta{key}: format_changed none -> metadata_key",
+		"BREAKING   mode: omitempty_changed omitempty -> none",
+	}, got)
+
+	assert.Nil(t, from.Check([]byte(`{"at":1,"mode":""}`)))
+	assert.EqualError(t, to.Check([]byte(`{"at":1,"mode":""}`)), "mode: enum")
+	assert.EqualError(t, from.Check([]byte(`{"at":0}`)), "at: required")
+	assert.EqualError(t, to.Check([]byte(`{"at":1,"meta":{"a b":"x"}}`)), "meta{key}: metadata_key")
+	assert.EqualError(t, from.Check([]byte(`{"at":1,"tags":["c"]}`)), "tags[]: enum")
+
+	doc, err := to.MarshalJSON()
+	assert.NoError(t, err)
+	parsed, err := Parse(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, to, parsed)
+}
@@ -0,0 +1,167 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FromStruct reads the contract of struct type t from its json and validate
+// tags. Tags after dive describe the elements, or a map's values, with those
+// between keys and endkeys describing its keys. Domain tags become the
+// Field's Format, except feature_vector=N and unit_interval, which are bounds.
+func FromStruct(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema: %s is not a struct", t)
+	}
+	s := &Schema{Properties: map[string]*Field{}}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f, err := fieldFromTag(sf.Type, sf.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s.%s: %w", t.Name(), sf.Name, err)
+		}
+		s.Properties[name] = f
+	}
+	return s, nil
+}
+
+// MustFromStruct is FromStruct for types known to be well tagged.
+func MustFromStruct(v any) *Schema {
+	s, err := FromStruct(reflect.TypeOf(v))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func fieldFromTag(t reflect.Type, tag string) (*Field, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	f := &Field{Type: jsonType(t)}
+	if f.Type == "" {
+		return nil, fmt.Errorf("unsupported kind %s", t.Kind())
+	}
+	own, elems, dive := cutRule(tag, "dive")
+	if err := f.applyTags(own); err != nil {
+		return nil, err
+	}
+	if f.Type != TypeArray && f.Type != TypeObject {
+		if dive {
+			return nil, fmt.Errorf("dive on %s", f.Type)
+		}
+		return f, nil
+	}
+	var err error
+	if f.Type == TypeObject {
+		if keys, rest, ok := cutRule(elems, "keys"); ok && keys == "" {
+			keys, elems, ok = cutRule(rest, "endkeys")
+			if !ok {
+				return nil, fmt.Errorf("keys without endkeys")
+			}
+			if f.Keys, err = fieldFromTag(t.Key(), keys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// The element tags may dive again.
+	if f.Items, err = fieldFromTag(t.Elem(), elems); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// cutRule splits a validate tag around its first rule named name, e.g. dive,
+// returning the rules before and after it.
+func cutRule(tag, name string) (before, after string, found bool) {
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == name {
+			return strings.Join(rules[:i], ","), strings.Join(rules[i+1:], ","), true
+		}
+	}
+	return tag, "", false
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInteger
+	case reflect.Float32, reflect.Float64:
+		return TypeNumber
+	case reflect.Bool:
+		return TypeBoolean
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	case reflect.Map:
+		return TypeObject
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return ""
+}
+
+func (f *Field) applyTags(tag string) error {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "":
+		case "omitempty":
+			f.OmitEmpty = true
+		case "required":
+			f.Required = true
+		case "min", "gte", "max", "lte", "len", "gt", "lt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return fmt.Errorf("%s=%s: %w", rule, param, err)
+			}
+			if f.Type != TypeNumber {
+				// Exclusive bounds on counts are inclusive ones off by one.
+				switch rule {
+				case "gt":
+					n, rule = n+1, "min"
+				case "lt":
+					n, rule = n-1, "max"
+				}
+			}
+			switch rule {
+			case "min", "gte", "gt":
+				f.Min = &n
+			case "max", "lte", "lt":
+				f.Max = &n
+			case "len":
+				f.Min, f.Max = &n, &n
+			}
+		case "oneof":
+			f.Enum = strings.Fields(param)
+		case "unit_interval":
+			zero, one := 0.0, 1.0
+			f.Min, f.Max = &zero, &one
+		case "feature_vector":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return fmt.Errorf("feature_vector=%s: %w", param, err)
+			}
+			one := 1.0
+			f.Min, f.Max = &one, &n
+		default:
+			f.Format = rule
+		}
+	}
+	return nil
+}
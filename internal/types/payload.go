@@ -6,8 +6,12 @@ type PredictRequest struct {
 	UserID     string    `json:"user_id" validate:"required,min=1,max=64"`
 	SessionID  string    `json:"session_id" validate:"required,min=1,max=64"`
 	Timestamp  int64     `json:"timestamp" validate:"required"`
-	Features   []float32 `json:"features" validate:"required,min=1,max=16384,dive"`
+	Features   []float32 `json:"features" validate:"required,feature_vector=16384"`
 	Metadata   map[string]string `json:"metadata" validate:"max=128,dive,keys,max=64,endkeys,max=4096"`
+	// Model selects a registered scorer; a /predict/{model} route parameter takes precedence.
+	Model      string    `json:"model,omitempty" validate:"omitempty,max=64"`
+	// Version names the schema version the body was written for; see guard.Versions.
+	Version    string    `json:"version,omitempty" validate:"omitempty,max=16"`
 }
 
 // PredictResponse is a compact response.
@@ -0,0 +1,140 @@
+package validate
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Domain tags registered on V(). The predicates behind them are exported so
+// the guard's raw scanner applies exactly the same rules.
+//
+//	finite            float is neither NaN nor infinite
+//	unit_interval     float in [0, 1]
+//	uuid4_lower       canonical lower-case version 4 UUID
+//	epoch_ms          Unix milliseconds between 2000 and 2100
+//	feature_vector=N  1 to N floats, each finite or NaN (a null sent as missing)
+//	metadata_key      1 to 64 of [A-Za-z0-9_.:-]
+var tags = map[string]validator.Func{
+	"finite":         floatTag(IsFinite),
+	"unit_interval":  floatTag(InUnitInterval),
+	"uuid4_lower":    func(fl validator.FieldLevel) bool { return isString(fl) && IsUUID4Lower(fl.Field().String()) },
+	"metadata_key":   func(fl validator.FieldLevel) bool { return isString(fl) && IsMetadataKey(fl.Field().String()) },
+	"epoch_ms":       epochMSTag,
+	"feature_vector": featureVectorTag,
+}
+
+func registerTags(v *validator.Validate) {
+	for name, fn := range tags {
+		if err := v.RegisterValidation(name, fn); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// IsFinite reports whether f is neither NaN nor infinite.
+func IsFinite(f float64) bool { return !math.IsNaN(f) && !math.IsInf(f, 0) }
+
+// InUnitInterval reports whether 0 <= f <= 1.
+func InUnitInterval(f float64) bool { return f >= 0 && f <= 1 }
+
+// IsUUID4Lower reports whether s is a lower-case version 4 UUID such as
+// 9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b.
+func IsUUID4Lower[S ~string | ~[]byte](s S) bool {
+	if len(s) != 36 || s[14] != '4' {
+		return false
+	}
+	if v := s[19]; v != '8' && v != '9' && v != 'a' && v != 'b' {
+		return false
+	}
+	for i := 0; i < 36; i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Plausible range for epoch_ms: 2000-01-01 to 2100-01-01 UTC.
+const (
+	minEpochMS = 946684800000
+	maxEpochMS = 4102444800000
+)
+
+// IsEpochMS reports whether n is a plausible Unix time in milliseconds.
+func IsEpochMS(n int64) bool { return n >= minEpochMS && n < maxEpochMS }
+
+// MaxMetadataKeyLen bounds metadata_key.
+const MaxMetadataKeyLen = 64
+
+// IsMetadataKey reports whether s is 1 to 64 of [A-Za-z0-9_.:-].
+func IsMetadataKey[S ~string | ~[]byte](s S) bool {
+	if len(s) == 0 || len(s) > MaxMetadataKeyLen {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == ':' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// FeatureVectorLen reports whether n entries fit feature_vector=max.
+func FeatureVectorLen(n, max int) bool { return n >= 1 && n <= max }
+
+// FeatureVectorParam parses the N of feature_vector=N.
+func FeatureVectorParam(param string) (int, bool) {
+	n, err := strconv.Atoi(param)
+	return n, err == nil && n >= 1
+}
+
+func isString(fl validator.FieldLevel) bool { return fl.Field().Kind() == reflect.String }
+
+func floatTag(pred func(float64) bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		switch f := fl.Field(); f.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return pred(f.Float())
+		}
+		return false
+	}
+}
+
+func epochMSTag(fl validator.FieldLevel) bool {
+	switch f := fl.Field(); f.Kind() {
+	case reflect.Int, reflect.Int64:
+		return IsEpochMS(f.Int())
+	}
+	return false
+}
+
+func featureVectorTag(fl validator.FieldLevel) bool {
+	max, ok := FeatureVectorParam(fl.Param())
+	f := fl.Field()
+	if !ok || f.Kind() != reflect.Slice || !FeatureVectorLen(f.Len(), max) {
+		return false
+	}
+	switch f.Type().Elem().Kind() {
+	case reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+	for i := 0; i < f.Len(); i++ {
+		if x := f.Index(i).Float(); math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package validate
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/jsoninputguard/internal/types"
+)
+
+func TestDomainTags(t *testing.T) {
+	type payload struct {
+		Score    float64           `validate:"finite,unit_interval"`
+		ID       string            `validate:"uuid4_lower"`
+		At       int64             `validate:"epoch_ms"`
+		Features []float32         `validate:"feature_vector=3"`
+		Meta     map[string]string `validate:"dive,keys,metadata_key,endkeys"`
+	}
+	ok := func() payload {
+		return payload{Score: 0.5, ID: "9b2e4f1c-3a5d-4e6f-8a7b-0c1d2e3f4a5b", At: 1_700_000_000_000,
+			Features: []float32{1, float32(math.NaN())}, Meta: map[string]string{"env.region:1": "x"}}
+	}
+	assert.NoError(t, V().Struct(ok()))
+
+	for name, mutate := range map[string]func(*payload){
+		"nan score":      func(p *payload) { p.Score = math.NaN() },
+		"score > 1":      func(p *payload) { p.Score = 1.5 },
+		"upper uuid":     func(p *payload) { p.ID = "9B2E4F1C-3A5D-4E6F-8A7B-0C1D2E3F4A5B" },
+		"uuid v1":        func(p *payload) { p.ID = "9b2e4f1c-3a5d-1e6f-8a7b-0c1d2e3f4a5b" },
+		"epoch seconds":  func(p *payload) { p.At = 1_700_000_000 },
+		"too many":       func(p *payload) { p.Features = []float32{1, 2, 3, 4} },
+		"empty":          func(p *payload) { p.Features = nil },
+		"infinite entry": func(p *payload) { p.Features = []float32{float32(math.Inf(1))} },
+		"key with space": func(p *payload) { p.Meta = map[string]string{"a b": "x"} },
+	} {
+		p := ok()
+		mutate(&p)
+		assert.Error(t, V().Struct(p), name)
+	}
+}
+
+func TestPredictRequestMetadataKeys(t *testing.T) {
+	// The contract predates metadata_key: any key up to 64 characters.
+	pr := types.PredictRequest{UserID: "u", SessionID: "s", Timestamp: 1, Features: []float32{1},
+		Metadata: map[string]string{"a b/ü": "x"}}
+	assert.NoError(t, V().Struct(pr))
+	pr.Metadata = map[string]string{strings.Repeat("k", 65): "x"}
+	assert.Error(t, V().Struct(pr))
+}
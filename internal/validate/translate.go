@@ -0,0 +1,186 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	"golang.org/x/text/language"
+)
+
+// Languages that validation messages are rendered in. The first is the
+// fallback.
+var Languages = []string{"en", "fr", "es", "de"}
+
+var languageMatcher = language.NewMatcher([]language.Tag{language.English, language.French, language.Spanish, language.German})
+
+// Negotiate picks the entry of Languages that best matches an
+// Accept-Language header, falling back to English.
+func Negotiate(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return Languages[0]
+	}
+	_, i := language.MatchStrings(languageMatcher, acceptLanguage)
+	return Languages[i]
+}
+
+var (
+	translateOnce sync.Once
+	uni           *ut.UniversalTranslator
+)
+
+// Translator returns the translator for lang, one of Languages, with the
+// built-in and domain tags registered on V(). Unknown languages get English.
+func Translator(lang string) ut.Translator {
+	translateOnce.Do(func() { uni = registerTranslations(V()) })
+	if t, ok := uni.GetTranslator(lang); ok {
+		return t
+	}
+	return uni.GetFallback()
+}
+
+// Messages renders each validation failure in err in lang, alongside the
+// failing field's JSON name. Both are nil if err is not a
+// validator.ValidationErrors.
+func Messages(err error, lang string) (fields, msgs []string) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, nil
+	}
+	t := Translator(lang)
+	for _, fe := range verrs {
+		fields = append(fields, fe.Field())
+		msgs = append(msgs, fe.Translate(t))
+	}
+	return fields, msgs
+}
+
+// The validator ships en, fr and es; de covers the built-in tags our
+// payloads use.
+var defaultTranslations = map[string]func(*validator.Validate, ut.Translator) error{
+	"en": en_translations.RegisterDefaultTranslations,
+	"fr": fr_translations.RegisterDefaultTranslations,
+	"es": es_translations.RegisterDefaultTranslations,
+	"de": registerGerman,
+}
+
+// Domain tag messages: {0} is the field, {1} the tag parameter.
+var tagTranslations = map[string]map[string]string{
+	"en": {
+		"finite":         "{0} must be a finite number",
+		"unit_interval":  "{0} must be between 0 and 1",
+		"uuid4_lower":    "{0} must be a lower-case version 4 UUID",
+		"epoch_ms":       "{0} must be a Unix time in milliseconds",
+		"feature_vector": "{0} must hold 1 to {1} numbers, none infinite",
+		"metadata_key":   "{0} must be 1 to 64 letters, digits or _ . : -",
+	},
+	"fr": {
+		"finite":         "{0} doit être un nombre fini",
+		"unit_interval":  "{0} doit être compris entre 0 et 1",
+		"uuid4_lower":    "{0} doit être un UUID version 4 en minuscules",
+		"epoch_ms":       "{0} doit être un temps Unix en millisecondes",
+		"feature_vector": "{0} doit contenir de 1 à {1} nombres, aucun infini",
+		"metadata_key":   "{0} doit comporter de 1 à 64 lettres, chiffres ou _ . : -",
+	},
+	"es": {
+		"finite":         "{0} debe ser un número finito",
+		"unit_interval":  "{0} debe estar entre 0 y 1",
+		"uuid4_lower":    "{0} debe ser un UUID versión 4 en minúsculas",
+		"epoch_ms":       "{0} debe ser un tiempo Unix en milisegundos",
+		"feature_vector": "{0} debe contener de 1 a {1} números, ninguno infinito",
+		"metadata_key":   "{0} debe tener de 1 a 64 letras, dígitos o _ . : -",
+	},
+	"de": {
+		"finite":         "{0} muss eine endliche Zahl sein",
+		"unit_interval":  "{0} muss zwischen 0 und 1 liegen",
+		"uuid4_lower":    "{0} muss eine UUID der Version 4 in Kleinbuchstaben sein",
+		"epoch_ms":       "{0} muss eine Unix-Zeit in Millisekunden sein",
+		"feature_vector": "{0} muss 1 bis {1} Zahlen enthalten, keine davon unendlich",
+		"metadata_key":   "{0} muss aus 1 bis 64 Buchstaben, Ziffern oder _ . : - bestehen",
+	},
+}
+
+func registerTranslations(v *validator.Validate) *ut.UniversalTranslator {
+	u := ut.New(en.New(), en.New(), fr.New(), es.New(), de.New())
+	for _, lang := range Languages {
+		t, _ := u.GetTranslator(lang)
+		if err := defaultTranslations[lang](v, t); err != nil {
+			panic(err)
+		}
+		for tag, text := range tagTranslations[lang] {
+			if err := v.RegisterTranslation(tag, t, addText(tag, text), translateParam); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return u
+}
+
+func addText(key, text string) validator.RegisterTranslationsFunc {
+	return func(t ut.Translator) error { return t.Add(key, text, false) }
+}
+
+func translateParam(t ut.Translator, fe validator.FieldError) string {
+	s, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+	if err != nil {
+		return fe.Error()
+	}
+	return s
+}
+
+// German texts for the built-in tags, keyed by tag and then by what the
+// field holds.
+var germanTexts = map[string][3]string{ // string, collection, number
+	"required": {"{0} ist ein Pflichtfeld", "{0} ist ein Pflichtfeld", "{0} ist ein Pflichtfeld"},
+	"min":      {"{0} muss mindestens {1} Zeichen lang sein", "{0} muss mindestens {1} Elemente enthalten", "{0} muss {1} oder größer sein"},
+	"max":      {"{0} darf höchstens {1} Zeichen lang sein", "{0} darf höchstens {1} Elemente enthalten", "{0} muss {1} oder kleiner sein"},
+	"len":      {"{0} muss genau {1} Zeichen lang sein", "{0} muss genau {1} Elemente enthalten", "{0} muss gleich {1} sein"},
+	"gt":       {"{0} muss länger als {1} Zeichen sein", "{0} muss mehr als {1} Elemente enthalten", "{0} muss größer als {1} sein"},
+	"gte":      {"{0} muss mindestens {1} Zeichen lang sein", "{0} muss mindestens {1} Elemente enthalten", "{0} muss {1} oder größer sein"},
+	"lt":       {"{0} muss kürzer als {1} Zeichen sein", "{0} muss weniger als {1} Elemente enthalten", "{0} muss kleiner als {1} sein"},
+	"lte":      {"{0} darf höchstens {1} Zeichen lang sein", "{0} darf höchstens {1} Elemente enthalten", "{0} muss {1} oder kleiner sein"},
+}
+
+func registerGerman(v *validator.Validate, t ut.Translator) error {
+	for tag, texts := range germanTexts {
+		register := func(t ut.Translator) error {
+			for i, text := range texts {
+				if err := t.Add(germanKey(tag, i), text, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		translate := func(t ut.Translator, fe validator.FieldError) string {
+			i := 2
+			switch fe.Kind() {
+			case reflect.String:
+				i = 0
+			case reflect.Slice, reflect.Map, reflect.Array:
+				i = 1
+			}
+			s, err := t.T(germanKey(fe.Tag(), i), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return s
+		}
+		if err := v.RegisterTranslation(tag, t, register, translate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func germanKey(tag string, kind int) string {
+	return tag + "-" + [...]string{"string", "items", "number"}[kind]
+}
@@ -0,0 +1,41 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                       "en",
+		"es-MX":                  "es",
+		"ja, de-AT;q=0.5":        "de",
+		"en-GB;q=0.3, fr;q=0.9":  "fr",
+		"pt-BR":                  "en",
+		"not a language header!": "en",
+	} {
+		assert.Equal(t, want, Negotiate(header), header)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	type payload struct {
+		UserID   string    `json:"user_id" validate:"uuid4_lower"`
+		Features []float32 `json:"features" validate:"feature_vector=2"`
+	}
+	err := V().Struct(payload{UserID: "x", Features: []float32{1, 2, 3}})
+
+	fields, msgs := Messages(err, "fr")
+	assert.Equal(t, []string{"user_id", "features"}, fields)
+	assert.Equal(t, []string{
+		"user_id doit être un UUID version 4 en minuscules",
+		"features doit contenir de 1 à 2 nombres, aucun infini",
+	}, msgs)
+
+	_, msgs = Messages(err, "xx")
+	assert.Equal(t, "user_id must be a lower-case version 4 UUID", msgs[0])
+
+	fields, _ = Messages(assert.AnError, "en")
+	assert.Nil(t, fields)
+}
@@ -1,6 +1,8 @@
 package validate
 
 import (
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/go-playground/validator/v10"
@@ -11,10 +13,24 @@ var (
 	v            *validator.Validate
 )
 
-// V returns a fast, singleton validator instance.
+// V returns a fast, singleton validator instance with the domain tags
+// (see tags.go) registered. Errors name fields by their JSON key.
 func V() *validator.Validate {
 	validateOnce.Do(func() {
 		v = validator.New(validator.WithRequiredStructEnabled())
+		v.RegisterTagNameFunc(jsonName)
+		registerTags(v)
 	})
 	return v
 }
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}